@@ -1,12 +1,11 @@
 package agollo
 
 import (
+	"context"
 	"encoding/json"
-	"io/ioutil"
+	"fmt"
 	"net/http"
 	"os"
-	"path"
-	"path/filepath"
 	"sync"
 	"time"
 )
@@ -21,12 +20,36 @@ var (
 
 type Agollo interface {
 	Start() <-chan *LongPollerError
+	StartWithContext(ctx context.Context) <-chan *LongPollerError
 	Stop()
+	Shutdown(ctx context.Context) error
 	Get(key string, opts ...GetOption) string
+	GetInt(key string, opts ...GetOption) int
+	GetIntE(key string, opts ...GetOption) (int, error)
+	GetBool(key string, opts ...GetOption) bool
+	GetBoolE(key string, opts ...GetOption) (bool, error)
+	GetFloat64(key string, opts ...GetOption) float64
+	GetFloat64E(key string, opts ...GetOption) (float64, error)
+	GetDuration(key string, opts ...GetOption) time.Duration
+	GetDurationE(key string, opts ...GetOption) (time.Duration, error)
+	GetStringSlice(key string, opts ...GetOption) []string
+	GetStringSliceE(key string, opts ...GetOption) ([]string, error)
 	GetNameSpace(namespace string) Configurations
+	GetNameSpaceWithContext(ctx context.Context, namespace string) Configurations
+	Unmarshal(namespace string, v interface{}) error
+	Bind(namespace string, ptr interface{}, opts ...BindOption) (*Binding, error)
+	AddChangeListener(namespace string, fn func(*ApolloResponse), opts ...ChangeListenerOption) *ChangeListener
+	Subscribe(namespace string, opts ...SubscriptionOption) *Subscription
+	History(namespace string) ([]*Backup, error)
+	Rollback(namespace, releaseKey string) error
+	Freeze(namespace string)
+	Unfreeze(namespace string) error
 	Watch() <-chan *ApolloResponse
 	WatchNamespace(namespace string, stop chan bool) <-chan *ApolloResponse
 	Options() Options
+	Ready() <-chan struct{}
+	WaitReady(ctx context.Context) error
+	Status() map[string]NamespaceStatus
 }
 
 type ApolloResponse struct {
@@ -47,18 +70,43 @@ type LongPollerError struct {
 }
 
 type agollo struct {
-	opts Options
+	opts   Options
+	client ContextApolloClient
 
 	notificationMap sync.Map // key: namespace value: notificationId
 	releaseKeyMap   sync.Map // key: namespace value: releaseKey
 	cache           sync.Map // key: namespace value: Configurations
 	initialized     sync.Map // key: namespace value: bool
+	clusters        sync.Map // key: namespace value: 配置实际所在的集群
+	requestClusters sync.Map // key: namespace value: 请求配置及长轮询时使用的集群
 
+	watchLock           sync.Mutex
 	watchCh             chan *ApolloResponse // watch all namespace
 	watchNamespaceChMap sync.Map             // key: namespace value: chan *ApolloResponse
 
+	bindingLock sync.RWMutex
+	bindings    map[string][]*Binding // key: namespace value: []*Binding
+
+	listenerLock sync.RWMutex
+	listeners    map[string][]*ChangeListener // key: namespace value: []*ChangeListener
+
+	subscriptionLock sync.RWMutex
+	subscriptions    map[string][]*Subscription // key: namespace value: []*Subscription
+
+	pinnedReleases sync.Map // key: namespace value: 回滚时的release_key
+
+	freezeLock sync.Mutex
+	frozen     map[string]*Config // key: namespace value: 冻结期间收到的最新release
+
+	releaseLock sync.Mutex // 串行化release的应用、Unfreeze及Rollback，先于freezeLock获取
+
 	errorsCh chan *LongPollerError
 
+	statusLock sync.RWMutex
+	status     map[string]*NamespaceStatus // key: namespace
+	readyCh    chan struct{}
+	readyOnce  sync.Once
+
 	runOnce      sync.Once
 	runHeartBeat sync.Once
 
@@ -66,7 +114,10 @@ type agollo struct {
 	stopCh   chan struct{}
 	stopLock sync.Mutex
 
-	backupLock sync.RWMutex
+	wg        sync.WaitGroup // Start、WatchNamespace等启动的goroutine
+	closed    bool           // watch、errors channel是否已关闭，修改时同时持有stopLock和closeLock
+	closeLock sync.RWMutex   // 发送watch、errors channel时持有读锁，防止向已关闭的channel发送
+	closeOnce sync.Once
 }
 
 func NewWithConfigFile(configFilePath string, opts ...Option) (Agollo, error) {
@@ -102,9 +153,15 @@ func NewWithConfigFile(configFilePath string, opts ...Option) (Agollo, error) {
 }
 
 func New(configServerURL, appID string, opts ...Option) (Agollo, error) {
+	return NewWithContext(context.Background(), configServerURL, appID, opts...)
+}
+
+// NewWithContext ctx用于控制初始化时加载PreloadNamespaces的请求
+func NewWithContext(ctx context.Context, configServerURL, appID string, opts ...Option) (Agollo, error) {
 	a := &agollo{
 		stopCh:   make(chan struct{}),
 		errorsCh: make(chan *LongPollerError),
+		readyCh:  make(chan struct{}),
 	}
 
 	options, err := newOptions(configServerURL, appID, opts...)
@@ -112,38 +169,45 @@ func New(configServerURL, appID string, opts ...Option) (Agollo, error) {
 		return nil, err
 	}
 	a.opts = options
+	a.client = toContextApolloClient(options.ApolloClient)
 
-	return a, a.initNamespace(a.opts.PreloadNamespaces...)
+	err = a.initNamespace(ctx, a.opts.PreloadNamespaces...)
+	a.checkReady()
+	return a, err
 }
 
-func (a *agollo) initNamespace(namespaces ...string) error {
-	var errs []error
+// initNamespace 初始化namespace，多个namespace初始化失败时返回*InitNamespaceError
+func (a *agollo) initNamespace(ctx context.Context, namespaces ...string) error {
+	errs := map[string]error{}
 	for _, namespace := range namespaces {
+		namespace = a.opts.remoteNamespace(namespace)
 		_, found := a.initialized.LoadOrStore(namespace, true)
 		if !found {
+			a.updateStatus(namespace, func(*NamespaceStatus) {})
+
 			// (1)读取配置 (2)设置初始化notificationMap
-			status, _, err := a.reloadNamespace(namespace)
+			status, _, err := a.reloadNamespace(ctx, namespace)
 
 			// 这里没法光凭靠error==nil来判断namespace是否存在，即使http请求失败，如果开启 容错，会导致error丢失
 			// 从而可能将一个不存在的namespace拿去调用getRemoteNotifications导致被hold
-			a.setNotificationIDFromRemote(namespace, status == http.StatusOK)
+			a.setNotificationIDFromRemote(ctx, namespace, status == http.StatusOK)
 
 			// 即使存在异常也需要继续初始化下去，有一些使用者会拂掠初始化时的错误
 			// 期望在未来某个时间点apollo的服务器恢复过来
 			if err != nil {
-				errs = append(errs, err)
+				errs[namespace] = err
 			}
 		}
 	}
 
 	if len(errs) > 0 {
-		return errs[0]
+		return &InitNamespaceError{Errors: errs}
 	}
 
 	return nil
 }
 
-func (a *agollo) setNotificationIDFromRemote(namespace string, exists bool) {
+func (a *agollo) setNotificationIDFromRemote(ctx context.Context, namespace string, exists bool) {
 	if !exists {
 		// 不能正常获取notificationID的设置为默认notificationID
 		// 为之后longPoll提供localNoticationID参数
@@ -160,7 +224,7 @@ func (a *agollo) setNotificationIDFromRemote(namespace string, exists bool) {
 	// 由于apollo去getRemoteNotifications获取一个不存在的namespace的notificationID时会hold请求90秒
 	// (1) 为防止意外传入一个不存在的namespace而发生上述情况，仅将成功获取配置在apollo存在的namespace,去初始化notificationID
 	// (2) 此处忽略error返回，在容灾逻辑下配置能正确读取而去获取notificationid可能会返回http请求失败，防止服务不能正常容灾启动
-	remoteNotifications, _ := a.getRemoteNotifications(localNotifications)
+	remoteNotifications, _ := a.getRemoteNotifications(ctx, a.getRequestCluster(namespace), localNotifications)
 	if len(remoteNotifications) > 0 {
 		for _, notification := range remoteNotifications {
 			// 设置namespace初始化的notificationID
@@ -172,11 +236,17 @@ func (a *agollo) setNotificationIDFromRemote(namespace string, exists bool) {
 	}
 }
 
-func (a *agollo) reloadNamespace(namespace string) (status int, conf Configurations, err error) {
+func (a *agollo) reloadNamespace(ctx context.Context, namespace string) (status int, conf Configurations, err error) {
 	var configServerURL string
 	configServerURL, err = a.opts.Balancer.Select()
 	if err != nil {
 		a.log("Action", "BalancerSelect", "Error", err)
+		a.setLastError(namespace, err)
+
+		// 没有可用的ConfigServer时，如果开启容灾，则读取备份
+		if backup, ok := a.restoreBackup(namespace, err); ok {
+			return status, backup, nil
+		}
 		return
 	}
 
@@ -184,29 +254,22 @@ func (a *agollo) reloadNamespace(namespace string) (status int, conf Configurati
 		config              *Config
 		cachedReleaseKey, _ = a.releaseKeyMap.LoadOrStore(namespace, "")
 	)
-	status, config, err = a.opts.ApolloClient.GetConfigsFromNonCache(
-		configServerURL,
-		a.opts.AppID,
-		a.opts.Cluster,
-		namespace,
-		ReleaseKey(cachedReleaseKey.(string)),
-	)
+	status, config, err = a.getConfigsFromNonCache(a.withConfigServerSelector(ctx, &configServerURL),
+		configServerURL, namespace, cachedReleaseKey.(string))
+	a.reportConfigServer(ctx, configServerURL, status, err)
 
 	switch status {
-	case http.StatusOK: // 正常响应
-		a.cache.Store(namespace, config.Configurations)     // 覆盖旧缓存
-		a.releaseKeyMap.Store(namespace, config.ReleaseKey) // 存储最新的release_key
-		conf = config.Configurations
-
-		// 备份配置
-		if err = a.backup(namespace, config.Configurations); err != nil {
-			a.log("BackupFile", a.opts.BackupFile, "Namespace", namespace,
-				"Action", "Backup", "Error", err)
-			return
+	case http.StatusOK: // 正常响应，已回滚或已冻结时release暂不生效
+		var applied bool
+		_, conf, applied, err = a.receiveRelease(namespace, config)
+		if !applied {
+			a.setLastError(namespace, nil)
 		}
 	case http.StatusNotModified: // 服务端未修改配置情况下返回304
 		conf = a.getNamespace(namespace)
+		a.confirmRelease(namespace, cachedReleaseKey.(string))
 	default:
+		a.setLastError(namespace, statusError(status, err))
 		a.log("ConfigServerUrl", configServerURL, "Namespace", namespace,
 			"Action", "GetConfigsFromNonCache", "ServerResponseStatus", status,
 			"Error", err)
@@ -214,24 +277,91 @@ func (a *agollo) reloadNamespace(namespace string) (status int, conf Configurati
 		conf = Configurations{}
 
 		// 异常状况下，如果开启容灾，则读取备份
-		if a.opts.FailTolerantOnBackupExists {
-			backupConfig, lerr := a.loadBackupByNamespace(namespace)
-			if lerr != nil {
-				a.log("BackupFile", a.opts.BackupFile, "Namespace", namespace,
-					"Action", "loadBackupByNamespace", "Error", lerr)
-				return
-			}
-
-			a.cache.Store(namespace, backupConfig)
-			conf = backupConfig
-			err = nil
-			return
+		if backup, ok := a.restoreBackup(namespace, statusError(status, err)); ok {
+			return status, backup, nil
 		}
 	}
 
 	return
 }
 
+// restoreBackup 开启容灾时使用备份作为namespace的配置，cause为访问apollo失败的原因，记录在状态中
+func (a *agollo) restoreBackup(namespace string, cause error) (Configurations, bool) {
+	if !a.opts.FailTolerantOnBackupExists {
+		return nil, false
+	}
+
+	backup, err := a.loadBackup(namespace)
+	if err != nil {
+		a.log("BackupFile", a.opts.BackupFile, "Namespace", namespace,
+			"Action", "LoadBackup", "Error", err)
+		return nil, false
+	}
+
+	conf := a.parseConfigurations(namespace, backup.Configurations)
+	a.cache.Store(namespace, conf)
+	a.releaseKeyMap.Store(namespace, backup.ReleaseKey) // 恢复备份时的release_key
+	a.setCluster(namespace, backup.Cluster)
+	a.setLoaded(namespace, NamespaceSourceBackup, backup.ReleaseKey, backup.FetchedAt, cause)
+	return conf, true
+}
+
+// receiveRelease 处理apollo返回的新release，已回滚到历史版本时忽略回滚时的release，
+// 已冻结时只记录最新的release，applied为false时newValue即为当前的配置
+func (a *agollo) receiveRelease(namespace string, config *Config) (oldValue, newValue Configurations, applied bool, err error) {
+	a.releaseLock.Lock()
+	defer a.releaseLock.Unlock()
+
+	oldValue = a.getNamespace(namespace)
+	if a.isPinned(namespace, config.ReleaseKey) || a.holdRelease(namespace, config) {
+		return oldValue, oldValue, false, nil
+	}
+
+	newValue, err = a.applyRelease(namespace, config)
+	return oldValue, newValue, true, err
+}
+
+// applyRelease 使用apollo返回的release更新缓存、release_key及状态，并备份原始配置，
+// 未通过Validator校验时保留旧的配置并返回*ValidationError
+func (a *agollo) applyRelease(namespace string, config *Config) (conf Configurations, err error) {
+	oldValue := a.getNamespace(namespace)
+	conf = a.parseConfigurations(namespace, config.Configurations)
+	if err = a.validate(namespace, config.ReleaseKey, oldValue, conf); err != nil {
+		a.log("Namespace", namespace, "ReleaseKey", config.ReleaseKey,
+			"Action", "Validate", "Error", err)
+		// 记录被拒绝的release_key，之后apollo返回304，直到有新的release
+		a.releaseKeyMap.Store(namespace, config.ReleaseKey)
+		a.setLastError(namespace, err)
+		return oldValue, err
+	}
+
+	a.cache.Store(namespace, conf)                      // 覆盖旧缓存
+	a.releaseKeyMap.Store(namespace, config.ReleaseKey) // 存储最新的release_key
+	a.setCluster(namespace, config.Cluster)
+	a.setLoaded(namespace, NamespaceSourceRemote, config.ReleaseKey, time.Now(), nil)
+
+	// 备份原始配置，缓存已经更新，备份失败只记录错误，不影响变更通知及NotificationID的更新
+	if err := a.backup(namespace, config.ReleaseKey, config.Configurations); err != nil {
+		a.log("BackupFile", a.opts.BackupFile, "Namespace", namespace,
+			"Action", "Backup", "Error", err)
+		a.setLastError(namespace, err)
+	}
+	if err := a.saveHistory(namespace, config.ReleaseKey, config.Configurations); err != nil {
+		a.log("Namespace", namespace, "Action", "SaveHistory", "Error", err)
+		a.setLastError(namespace, err)
+	}
+	return conf, nil
+}
+
+// parseConfigurations 解析json/yaml/xml等格式namespace的content，解析失败时记录日志并返回原始配置
+func (a *agollo) parseConfigurations(namespace string, conf Configurations) Configurations {
+	parsed, err := parseConfigurations(a.opts.getConfigType(namespace), conf)
+	if err != nil {
+		a.log("Namespace", namespace, "Action", "ParseConfigurations", "Error", err)
+	}
+	return parsed
+}
+
 func (a *agollo) Get(key string, opts ...GetOption) string {
 	getOpts := a.opts.newGetOptions(opts...)
 
@@ -244,10 +374,111 @@ func (a *agollo) Get(key string, opts ...GetOption) string {
 	return v
 }
 
+// getValue 读取key对应的原始值，key不存在时返回WithDefault设置的默认值，
+// 两者都不存在时found为false。非E版本的getter在解析失败时同样回退到默认值
+func (a *agollo) getValue(key string, opts ...GetOption) (val interface{}, found bool) {
+	getOpts := a.opts.newGetOptions(opts...)
+
+	val, found = a.GetNameSpace(getOpts.Namespace)[key]
+	if !found && getOpts.DefaultValue != "" {
+		return getOpts.DefaultValue, true
+	}
+	return
+}
+
+func (a *agollo) GetInt(key string, opts ...GetOption) int {
+	v, err := a.GetIntE(key, opts...)
+	if err != nil {
+		v, _ = ToIntE(a.opts.newGetOptions(opts...).DefaultValue)
+	}
+	return v
+}
+
+func (a *agollo) GetIntE(key string, opts ...GetOption) (int, error) {
+	val, found := a.getValue(key, opts...)
+	if !found {
+		return 0, nil
+	}
+	return ToIntE(val)
+}
+
+func (a *agollo) GetBool(key string, opts ...GetOption) bool {
+	v, err := a.GetBoolE(key, opts...)
+	if err != nil {
+		v, _ = ToBoolE(a.opts.newGetOptions(opts...).DefaultValue)
+	}
+	return v
+}
+
+func (a *agollo) GetBoolE(key string, opts ...GetOption) (bool, error) {
+	val, found := a.getValue(key, opts...)
+	if !found {
+		return false, nil
+	}
+	return ToBoolE(val)
+}
+
+func (a *agollo) GetFloat64(key string, opts ...GetOption) float64 {
+	v, err := a.GetFloat64E(key, opts...)
+	if err != nil {
+		v, _ = ToFloat64E(a.opts.newGetOptions(opts...).DefaultValue)
+	}
+	return v
+}
+
+func (a *agollo) GetFloat64E(key string, opts ...GetOption) (float64, error) {
+	val, found := a.getValue(key, opts...)
+	if !found {
+		return 0, nil
+	}
+	return ToFloat64E(val)
+}
+
+func (a *agollo) GetDuration(key string, opts ...GetOption) time.Duration {
+	v, err := a.GetDurationE(key, opts...)
+	if err != nil {
+		v, _ = ToDurationE(a.opts.newGetOptions(opts...).DefaultValue)
+	}
+	return v
+}
+
+func (a *agollo) GetDurationE(key string, opts ...GetOption) (time.Duration, error) {
+	val, found := a.getValue(key, opts...)
+	if !found {
+		return 0, nil
+	}
+	return ToDurationE(val)
+}
+
+func (a *agollo) GetStringSlice(key string, opts ...GetOption) []string {
+	v, err := a.GetStringSliceE(key, opts...)
+	if err != nil {
+		v, _ = ToStringSliceE(a.opts.newGetOptions(opts...).DefaultValue)
+	}
+	return v
+}
+
+func (a *agollo) GetStringSliceE(key string, opts ...GetOption) ([]string, error) {
+	val, found := a.getValue(key, opts...)
+	if !found {
+		return []string{}, nil
+	}
+	return ToStringSliceE(val)
+}
+
 func (a *agollo) GetNameSpace(namespace string) Configurations {
+	return a.GetNameSpaceWithContext(context.Background(), namespace)
+}
+
+// GetNameSpaceWithContext ctx用于控制AutoFetchOnCacheMiss时加载namespace的请求
+func (a *agollo) GetNameSpaceWithContext(ctx context.Context, namespace string) Configurations {
+	namespace = a.opts.remoteNamespace(namespace)
 	config, found := a.cache.LoadOrStore(namespace, Configurations{})
 	if !found && a.opts.AutoFetchOnCacheMiss {
-		err := a.initNamespace(namespace)
+		ctx, cancel := a.withStopContext(ctx)
+		defer cancel()
+
+		err := a.initNamespace(ctx, namespace)
 		if err != nil {
 			a.log("Action", "InitNamespace", "Error", err)
 		}
@@ -257,6 +488,10 @@ func (a *agollo) GetNameSpace(namespace string) Configurations {
 	return config.(Configurations)
 }
 
+func (a *agollo) Unmarshal(namespace string, v interface{}) error {
+	return a.GetNameSpace(namespace).Unmarshal(v)
+}
+
 func (a *agollo) getNamespace(namespace string) Configurations {
 	v, ok := a.cache.Load(namespace)
 	if !ok {
@@ -271,45 +506,85 @@ func (a *agollo) Options() Options {
 
 // 启动goroutine去轮训apollo通知接口
 func (a *agollo) Start() <-chan *LongPollerError {
+	return a.StartWithContext(context.Background())
+}
+
+// StartWithContext ctx结束或者调用Stop时停止轮训，并取消正在进行中的请求，
+// 多次调用时仅第一次调用的ctx生效
+func (a *agollo) StartWithContext(ctx context.Context) <-chan *LongPollerError {
 	a.runOnce.Do(func() {
-		go func() {
+		ctx, cancel := a.withStopContext(ctx)
+		a.goroutine(func() {
+			defer cancel()
+
 			timer := time.NewTimer(a.opts.LongPollerInterval)
 			defer timer.Stop()
 
-			for !a.shouldStop() {
+			for {
 				select {
 				case <-timer.C:
-					a.longPoll()
-					timer.Reset(a.opts.LongPollerInterval)
-				case <-a.stopCh:
+					timer.Reset(a.nextLongPollInterval(a.longPoll(ctx)))
+				case <-ctx.Done():
 					return
 				}
 			}
-		}()
+		})
 	})
 
 	if a.opts.EnableHeartBeat {
 		a.runHeartBeat.Do(func() {
-			go func() {
+			ctx, cancel := a.withStopContext(ctx)
+			a.goroutine(func() {
+				defer cancel()
+
 				timer := time.NewTimer(a.opts.HeartBeatInterval)
 				defer timer.Stop()
-				for !a.shouldStop() {
+				for {
 					select {
 					case <-timer.C:
-						a.heartBeat()
+						a.heartBeat(ctx)
 						timer.Reset(a.opts.HeartBeatInterval)
-					case <-a.stopCh:
+					case <-ctx.Done():
 						return
 					}
 				}
-			}()
+			})
 		})
 	}
 
 	return a.errorsCh
 }
 
-func (a *agollo) heartBeat() {
+// goroutine 启动一个Shutdown时需要等待退出的goroutine，agollo停止后不再启动
+func (a *agollo) goroutine(f func()) bool {
+	a.stopLock.Lock()
+	defer a.stopLock.Unlock()
+	if a.stop {
+		return false
+	}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		f()
+	}()
+	return true
+}
+
+// withStopContext 返回一个在ctx结束或者agollo停止时都会被取消的context
+func (a *agollo) withStopContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-a.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func (a *agollo) heartBeat(ctx context.Context) {
 	var configServerURL string
 	configServerURL, err := a.opts.Balancer.Select()
 	if err != nil {
@@ -320,49 +595,48 @@ func (a *agollo) heartBeat() {
 	a.releaseKeyMap.Range(func(namespace, cachedReleaseKey interface{}) bool {
 		var config *Config
 		namespaceStr := namespace.(string)
-		status, config, err := a.opts.ApolloClient.GetConfigsFromNonCache(
-			configServerURL,
-			a.opts.AppID,
-			a.opts.Cluster,
-			namespaceStr,
-			ReleaseKey(cachedReleaseKey.(string)),
-		)
-		if err != nil {
+		status, config, err := a.getConfigsFromNonCache(a.withConfigServerSelector(ctx, &configServerURL),
+			configServerURL, namespaceStr, cachedReleaseKey.(string))
+		a.reportConfigServer(ctx, configServerURL, status, err)
+		if err != nil || status != http.StatusOK {
 			return true
 		}
-		if status == http.StatusOK {
-			oldValue := a.getNamespace(namespaceStr)
-			a.cache.Store(namespace, config.Configurations)
-			a.releaseKeyMap.Store(namespace, config.ReleaseKey)
-			if err = a.backup(namespaceStr, config.Configurations); err != nil {
-				a.log("BackupFile", a.opts.BackupFile, "Namespace", namespace,
-					"Action", "Backup", "Error", err)
-			}
-			a.sendWatchCh(namespaceStr, oldValue, config.Configurations)
-			a.notificationMap.Store(namespaceStr, config.ReleaseKey)
+
+		oldValue, newValue, applied, err := a.receiveRelease(namespaceStr, config)
+		if !applied {
+			return true
+		}
+		if isValidationError(err) {
+			a.sendErrorsCh(configServerURL, nil, namespaceStr, err)
+			return true
 		}
+		a.sendWatchCh(namespaceStr, oldValue, newValue)
+		a.notificationMap.Store(namespaceStr, config.ReleaseKey)
 		return true
 	})
 }
 
-func (a *agollo) shouldStop() bool {
-	select {
-	case <-a.stopCh:
-		return true
-	default:
-		return false
+// nextLongPollInterval 长轮询失败时按LongPollerSchedulePolicy退避，成功后恢复LongPollerInterval
+func (a *agollo) nextLongPollInterval(err error) time.Duration {
+	if err == nil {
+		a.opts.LongPollerSchedulePolicy.Success()
+		return a.opts.LongPollerInterval
 	}
-}
 
-func (a *agollo) longPoll() {
-	localNotifications := a.getLocalNotifications()
+	if d := a.opts.LongPollerSchedulePolicy.Fail(); d > a.opts.LongPollerInterval {
+		return d
+	}
+	return a.opts.LongPollerInterval
+}
 
+// longPoll 返回获取notifications时的错误，用于计算下一次长轮询的等待时间
+func (a *agollo) longPoll(ctx context.Context) error {
 	// 这里有个问题是非预加载的namespace，如果在Start开启监听后才被initNamespace
 	// 需要等待90秒后的下一次轮训才能收到事件通知
-	notifications, err := a.getRemoteNotifications(localNotifications)
+	notifications, err := a.pollNotifications(ctx)
 	if err != nil {
 		a.sendErrorsCh("", nil, "", err)
-		return
+		return err
 	}
 
 	// HTTP Status: 200时，正常返回notifications数据，数组含有需要更新namespace和notificationID
@@ -372,7 +646,7 @@ func (a *agollo) longPoll() {
 		oldValue := a.getNamespace(notification.NamespaceName)
 
 		// 更新namespace
-		status, newValue, err := a.reloadNamespace(notification.NamespaceName)
+		status, newValue, err := a.reloadNamespace(ctx, notification.NamespaceName)
 
 		if err == nil {
 			// Notifications 有更新，但是 GetConfigsFromNonCache 返回 304，
@@ -380,8 +654,14 @@ func (a *agollo) longPoll() {
 			if status == http.StatusNotModified {
 				continue
 			}
+
+			// 已回滚或者已冻结时配置不会变化，更新NotificationID避免重复获取
+			if a.isHeld(notification.NamespaceName) {
+				a.notificationMap.Store(notification.NamespaceName, notification.NotificationID)
+				continue
+			}
 			
-			if len(oldValue.Different(newValue)) == 0 {
+			if len(oldValue.DifferentWith(newValue, a.opts.Comparer)) == 0 {
 				// case 可能是apollo集群搭建问题
 				// GetConfigsFromNonCache 返回了了一模一样的数据，但是http.status code == 200
 				// 导致NotificationID更新了，但是真实的配置没有更新，而后续也不会获取到新配置，除非有新的变更触发
@@ -396,9 +676,14 @@ func (a *agollo) longPoll() {
 			// 访问apollo失败导致notificationid已是最新，而配置不是最新
 			a.notificationMap.Store(notification.NamespaceName, notification.NotificationID)
 		} else {
+			if isValidationError(err) {
+				// 被拒绝的release不会再生效，更新NotificationID避免重复获取
+				a.notificationMap.Store(notification.NamespaceName, notification.NotificationID)
+			}
 			a.sendErrorsCh("", notifications, notification.NamespaceName, err)
 		}
 	}
+	return nil
 }
 
 func (a *agollo) Stop() {
@@ -416,7 +701,64 @@ func (a *agollo) Stop() {
 	close(a.stopCh)
 }
 
+// Shutdown 停止agollo，取消正在进行中的请求，并等待Start、WatchNamespace以及Balancer启动的goroutine退出，
+// 全部退出后关闭Start、Watch、WatchNamespace返回的channel以及Subscribe的订阅，使用者的range循环可以正常结束。
+// ctx结束时goroutine仍未全部退出则返回ctx.Err()，此时不会关闭channel
+func (a *agollo) Shutdown(ctx context.Context) error {
+	a.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+
+	if s, ok := a.opts.Balancer.(balancerShutdowner); ok {
+		if err := s.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	a.closeOnce.Do(a.closeChannels)
+	return nil
+}
+
+func (a *agollo) closeChannels() {
+	a.stopLock.Lock()
+	defer a.stopLock.Unlock()
+
+	// Shutdown之后Rollback、Unfreeze等仍可能触发发送，等待进行中的发送结束后再关闭
+	a.closeLock.Lock()
+	defer a.closeLock.Unlock()
+	a.closed = true
+
+	close(a.errorsCh)
+
+	a.watchLock.Lock()
+	if a.watchCh == nil {
+		a.watchCh = make(chan *ApolloResponse)
+	}
+	close(a.watchCh)
+	a.watchLock.Unlock()
+
+	a.watchNamespaceChMap.Range(func(key, val interface{}) bool {
+		close(val.(chan *ApolloResponse))
+		a.watchNamespaceChMap.Delete(key)
+		return true
+	})
+
+	a.closeSubscriptions()
+}
+
 func (a *agollo) Watch() <-chan *ApolloResponse {
+	a.watchLock.Lock()
+	defer a.watchLock.Unlock()
 	if a.watchCh == nil {
 		a.watchCh = make(chan *ApolloResponse)
 	}
@@ -425,16 +767,33 @@ func (a *agollo) Watch() <-chan *ApolloResponse {
 }
 
 func (a *agollo) WatchNamespace(namespace string, stop chan bool) <-chan *ApolloResponse {
-	watchNamespace := fixWatchNamespace(namespace)
+	a.stopLock.Lock()
+	defer a.stopLock.Unlock()
+	if a.closed {
+		// Shutdown之后返回已关闭的channel
+		watchCh := make(chan *ApolloResponse)
+		close(watchCh)
+		return watchCh
+	}
+
+	watchNamespace := a.opts.fixWatchNamespace(namespace)
 	watchCh, exists := a.watchNamespaceChMap.LoadOrStore(watchNamespace, make(chan *ApolloResponse))
-	if !exists {
+	if !exists && !a.stop {
+		a.wg.Add(1)
 		go func() {
+			defer a.wg.Done()
+
 			// 非预加载以外的namespace,初始化基础meta信息,否则没有longpoll
-			err := a.initNamespace(namespace)
+			ctx, cancel := a.withStopContext(context.Background())
+			err := a.initNamespace(ctx, namespace)
+			cancel()
 			if err != nil {
-				watchCh.(chan *ApolloResponse) <- &ApolloResponse{
+				select {
+				case watchCh.(chan *ApolloResponse) <- &ApolloResponse{
 					Namespace: namespace,
 					Error:     err,
+				}:
+				case <-a.stopCh:
 				}
 			}
 
@@ -451,18 +810,8 @@ func (a *agollo) WatchNamespace(namespace string, stop chan bool) <-chan *Apollo
 	return watchCh.(chan *ApolloResponse)
 }
 
-func fixWatchNamespace(namespace string) string {
-	// fix: 传给apollo类似test.properties这种namespace
-	// 通知回来的NamespaceName却没有.properties后缀，追加.properties后缀来修正此问题
-	ext := path.Ext(namespace)
-	if ext == "" {
-		namespace = namespace + "." + defaultConfigType
-	}
-	return namespace
-}
-
 func (a *agollo) sendWatchCh(namespace string, oldVal, newVal Configurations) {
-	changes := oldVal.Different(newVal)
+	changes := oldVal.DifferentWith(newVal, a.opts.Comparer)
 	if len(changes) == 0 {
 		return
 	}
@@ -474,6 +823,17 @@ func (a *agollo) sendWatchCh(namespace string, oldVal, newVal Configurations) {
 		Changes:   changes,
 	}
 
+	// 先更新绑定的结构体，保证监听者收到事件时Binding.Load已是最新的配置
+	a.updateBindings(namespace, newVal)
+	a.notifyChangeListeners(resp)
+	a.sendSubscriptions(resp)
+
+	a.closeLock.RLock()
+	defer a.closeLock.RUnlock()
+	if a.closed {
+		return
+	}
+
 	timer := time.NewTimer(defaultWatchTimeout)
 	for _, watchCh := range a.getWatchChs(namespace) {
 		select {
@@ -487,11 +847,13 @@ func (a *agollo) sendWatchCh(namespace string, oldVal, newVal Configurations) {
 
 func (a *agollo) getWatchChs(namespace string) []chan *ApolloResponse {
 	var chs []chan *ApolloResponse
+	a.watchLock.Lock()
 	if a.watchCh != nil {
 		chs = append(chs, a.watchCh)
 	}
+	a.watchLock.Unlock()
 
-	watchNamespace := fixWatchNamespace(namespace)
+	watchNamespace := a.opts.fixWatchNamespace(namespace)
 	if watchNamespaceCh, found := a.watchNamespaceChMap.Load(watchNamespace); found {
 		chs = append(chs, watchNamespaceCh.(chan *ApolloResponse))
 	}
@@ -510,6 +872,13 @@ func (a *agollo) sendErrorsCh(configServerURL string, notifications []Notificati
 		Namespace:       namespace,
 		Err:             err,
 	}
+
+	a.closeLock.RLock()
+	defer a.closeLock.RUnlock()
+	if a.closed {
+		return
+	}
+
 	select {
 	case a.errorsCh <- longPollerError:
 
@@ -530,60 +899,29 @@ func (a *agollo) log(kvs ...interface{}) {
 	)
 }
 
-func (a *agollo) backup(namespace string, config Configurations) error {
-	backup, err := a.loadBackup()
-	if err != nil {
-		backup = map[string]Configurations{}
-	}
-
-	a.backupLock.Lock()
-	defer a.backupLock.Unlock()
-
-	backup[namespace] = config
-	data, err := json.Marshal(backup)
-	if err != nil {
-		return err
-	}
-
-	dir := filepath.Dir(a.opts.BackupFile)
-	if _, err = os.Stat(dir); os.IsNotExist(err) {
-		err = os.MkdirAll(dir, 0755)
-		if err != nil && !os.IsExist(err) {
-			return err
-		}
-	}
-
-	return ioutil.WriteFile(a.opts.BackupFile, data, 0644)
+func (a *agollo) backup(namespace, releaseKey string, config Configurations) error {
+	return a.opts.BackupStore.Save(namespace,
+		NewBackup(namespace, a.opts.AppID, a.getCluster(namespace), releaseKey, config))
 }
 
-func (a *agollo) loadBackup() (map[string]Configurations, error) {
-	a.backupLock.RLock()
-	defer a.backupLock.RUnlock()
-
-	if _, err := os.Stat(a.opts.BackupFile); err != nil {
-		return nil, err
-	}
-
-	data, err := ioutil.ReadFile(a.opts.BackupFile)
+// loadBackup 读取备份并校验checksum，设置了BackupMaxAge时拒绝过期的备份
+func (a *agollo) loadBackup(namespace string) (*Backup, error) {
+	backup, err := a.opts.BackupStore.Load(namespace)
 	if err != nil {
 		return nil, err
 	}
 
-	backup := map[string]Configurations{}
-	err = json.Unmarshal(data, &backup)
-	if err != nil {
+	if err = backup.Verify(); err != nil {
 		return nil, err
 	}
-	return backup, nil
-}
 
-func (a *agollo) loadBackupByNamespace(namespace string) (Configurations, error) {
-	backup, err := a.loadBackup()
-	if err != nil {
-		return nil, err
+	if a.opts.BackupMaxAge > 0 {
+		// 旧版本的备份没有获取时间，无法判断是否过期，同样拒绝
+		if age := backup.Age(); age < 0 || age > a.opts.BackupMaxAge {
+			return nil, ErrBackupExpired
+		}
 	}
-
-	return backup[namespace], nil
+	return backup, nil
 }
 
 // getRemoteNotifications
@@ -592,29 +930,65 @@ func (a *agollo) loadBackupByNamespace(namespace string) (Configurations, error)
 // 请求被hold 90秒的情况:
 // 1. 请求的notificationID和apollo服务器中的ID相等
 // 2. 请求的namespace都是在apollo中不存在的
-func (a *agollo) getRemoteNotifications(req []Notification) ([]Notification, error) {
+func (a *agollo) getRemoteNotifications(ctx context.Context, cluster string, req []Notification) ([]Notification, error) {
 	configServerURL, err := a.opts.Balancer.Select()
 	if err != nil {
 		a.log("ConfigServerUrl", configServerURL, "Error", err, "Action", "Balancer.Select")
 		return nil, err
 	}
 
-	status, notifications, err := a.opts.ApolloClient.Notifications(
+	status, notifications, err := a.client.NotificationsWithContext(
+		ctx,
 		configServerURL,
 		a.opts.AppID,
-		a.opts.Cluster,
+		cluster,
 		req,
 	)
+	a.reportConfigServer(ctx, configServerURL, status, err)
 	if err != nil {
 		a.log("ConfigServerUrl", configServerURL,
 			"Notifications", req, "ServerResponseStatus", status,
 			"Error", err, "Action", "LongPoll")
 		return nil, err
 	}
+	// 401、403等非200/304的状态同样按失败处理并退避，避免无间隔的重复轮训。
+	// 没有需要监听的namespace时不会发出请求，status为0
+	if status != 0 && status != http.StatusOK && status != http.StatusNotModified {
+		err = fmt.Errorf("notifications: unexpected status %d", status)
+		a.log("ConfigServerUrl", configServerURL,
+			"Notifications", req, "ServerResponseStatus", status,
+			"Error", err, "Action", "LongPoll")
+		return nil, err
+	}
 
 	return notifications, nil
 }
 
+// reportConfigServer 将访问config server的结果反馈给实现了BalancerReporter的Balancer，
+// 5xx视为失败，ctx取消导致的失败不反馈
+func (a *agollo) reportConfigServer(ctx context.Context, configServerURL string, status int, err error) {
+	r, ok := a.opts.Balancer.(BalancerReporter)
+	if !ok || ctx.Err() != nil {
+		return
+	}
+	if err == nil && status >= http.StatusInternalServerError {
+		err = statusError(status, nil)
+	}
+	r.Report(configServerURL, err)
+}
+
+// withConfigServerSelector 请求失败重试时上报失败的ConfigServer并通过Balancer选择新的ConfigServer，
+// configServerURL始终为最后一次请求使用的ConfigServer
+func (a *agollo) withConfigServerSelector(ctx context.Context, configServerURL *string) context.Context {
+	return withServerSelector(ctx, func(failedURL string, status int, err error) string {
+		a.reportConfigServer(ctx, failedURL, status, err)
+		if next, serr := a.opts.Balancer.Select(); serr == nil {
+			*configServerURL = next
+		}
+		return *configServerURL
+	})
+}
+
 func (a *agollo) getLocalNotifications() []Notification {
 	var notifications []Notification
 
@@ -649,18 +1023,102 @@ func Start() <-chan *LongPollerError {
 	return defaultAgollo.Start()
 }
 
+func StartWithContext(ctx context.Context) <-chan *LongPollerError {
+	return defaultAgollo.StartWithContext(ctx)
+}
+
 func Stop() {
 	defaultAgollo.Stop()
 }
 
+func Shutdown(ctx context.Context) error {
+	return defaultAgollo.Shutdown(ctx)
+}
+
 func Get(key string, opts ...GetOption) string {
 	return defaultAgollo.Get(key, opts...)
 }
 
+func GetInt(key string, opts ...GetOption) int {
+	return defaultAgollo.GetInt(key, opts...)
+}
+
+func GetIntE(key string, opts ...GetOption) (int, error) {
+	return defaultAgollo.GetIntE(key, opts...)
+}
+
+func GetBool(key string, opts ...GetOption) bool {
+	return defaultAgollo.GetBool(key, opts...)
+}
+
+func GetBoolE(key string, opts ...GetOption) (bool, error) {
+	return defaultAgollo.GetBoolE(key, opts...)
+}
+
+func GetFloat64(key string, opts ...GetOption) float64 {
+	return defaultAgollo.GetFloat64(key, opts...)
+}
+
+func GetFloat64E(key string, opts ...GetOption) (float64, error) {
+	return defaultAgollo.GetFloat64E(key, opts...)
+}
+
+func GetDuration(key string, opts ...GetOption) time.Duration {
+	return defaultAgollo.GetDuration(key, opts...)
+}
+
+func GetDurationE(key string, opts ...GetOption) (time.Duration, error) {
+	return defaultAgollo.GetDurationE(key, opts...)
+}
+
+func GetStringSlice(key string, opts ...GetOption) []string {
+	return defaultAgollo.GetStringSlice(key, opts...)
+}
+
+func GetStringSliceE(key string, opts ...GetOption) ([]string, error) {
+	return defaultAgollo.GetStringSliceE(key, opts...)
+}
+
 func GetNameSpace(namespace string) Configurations {
 	return defaultAgollo.GetNameSpace(namespace)
 }
 
+func GetNameSpaceWithContext(ctx context.Context, namespace string) Configurations {
+	return defaultAgollo.GetNameSpaceWithContext(ctx, namespace)
+}
+
+func Unmarshal(namespace string, v interface{}) error {
+	return defaultAgollo.Unmarshal(namespace, v)
+}
+
+func Bind(namespace string, ptr interface{}, opts ...BindOption) (*Binding, error) {
+	return defaultAgollo.Bind(namespace, ptr, opts...)
+}
+
+func AddChangeListener(namespace string, fn func(*ApolloResponse), opts ...ChangeListenerOption) *ChangeListener {
+	return defaultAgollo.AddChangeListener(namespace, fn, opts...)
+}
+
+func Subscribe(namespace string, opts ...SubscriptionOption) *Subscription {
+	return defaultAgollo.Subscribe(namespace, opts...)
+}
+
+func History(namespace string) ([]*Backup, error) {
+	return defaultAgollo.History(namespace)
+}
+
+func Rollback(namespace, releaseKey string) error {
+	return defaultAgollo.Rollback(namespace, releaseKey)
+}
+
+func Freeze(namespace string) {
+	defaultAgollo.Freeze(namespace)
+}
+
+func Unfreeze(namespace string) error {
+	return defaultAgollo.Unfreeze(namespace)
+}
+
 func Watch() <-chan *ApolloResponse {
 	return defaultAgollo.Watch()
 }
@@ -669,6 +1127,18 @@ func WatchNamespace(namespace string, stop chan bool) <-chan *ApolloResponse {
 	return defaultAgollo.WatchNamespace(namespace, stop)
 }
 
+func Ready() <-chan struct{} {
+	return defaultAgollo.Ready()
+}
+
+func WaitReady(ctx context.Context) error {
+	return defaultAgollo.WaitReady(ctx)
+}
+
+func Status() map[string]NamespaceStatus {
+	return defaultAgollo.Status()
+}
+
 func GetAgollo() Agollo {
 	return defaultAgollo
 }
@@ -1,6 +1,7 @@
 package agollo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -322,3 +323,186 @@ func TestAgollo(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestAgolloTypedGetters(t *testing.T) {
+	backupfile, err := ioutil.TempFile("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(backupfile.Name())
+
+	client := &mockApolloClient{
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			return 200, &Config{
+				AppID:         appID,
+				Cluster:       cluster,
+				NamespaceName: namespace,
+				Configurations: map[string]interface{}{
+					"int":      "100",
+					"bool":     "true",
+					"float":    "1.5",
+					"duration": "3s",
+					"slice":    "a,b, c",
+					"bad":      "foo",
+				},
+				ReleaseKey: "1",
+			}, nil
+		},
+	}
+
+	a, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		PreloadNamespaces("application"),
+		BackupFile(backupfile.Name()),
+	)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 100, a.GetInt("int"))
+	assert.Equal(t, true, a.GetBool("bool"))
+	assert.Equal(t, 1.5, a.GetFloat64("float"))
+	assert.Equal(t, 3*time.Second, a.GetDuration("duration"))
+	assert.Equal(t, []string{"a", "b", "c"}, a.GetStringSlice("slice"))
+
+	// key不存在时使用默认值
+	assert.Equal(t, 8, a.GetInt("not_exists", WithDefault("8")))
+	assert.Equal(t, 5*time.Second, a.GetDuration("not_exists", WithDefault("5s")))
+	assert.Equal(t, 0, a.GetInt("not_exists"))
+
+	_, err = a.GetIntE("bad")
+	assert.Error(t, err)
+	_, err = a.GetBoolE("bad")
+	assert.Error(t, err)
+	assert.Equal(t, 0, a.GetInt("bad"))
+
+	// 解析失败时非E版本回退到默认值
+	assert.Equal(t, 8, a.GetInt("bad", WithDefault("8")))
+	assert.Equal(t, true, a.GetBool("bad", WithDefault("true")))
+	assert.Equal(t, 3*time.Second, a.GetDuration("bad", WithDefault("3s")))
+}
+
+func TestAgolloStructuredNamespace(t *testing.T) {
+	backupfile, err := ioutil.TempFile("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(backupfile.Name())
+
+	content := "db:\n  host: localhost\n  timeout: 3s\n"
+	client := &mockApolloClient{
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			return 200, &Config{
+				NamespaceName:  namespace,
+				Configurations: Configurations{ContentKey: content},
+				ReleaseKey:     "1",
+			}, nil
+		},
+	}
+
+	a, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		PreloadNamespaces("datasource.yaml"),
+		BackupFile(backupfile.Name()),
+	)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "localhost", a.Get("db.host", WithNamespace("datasource.yaml")))
+	assert.Equal(t, 3*time.Second, a.GetDuration("db.timeout", WithNamespace("datasource.yaml")))
+	assert.Equal(t, content, a.Get(ContentKey, WithNamespace("datasource.yaml")))
+
+	// 备份中保存原始的content
+	backup, err := a.Options().BackupStore.Load("datasource.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, Configurations{ContentKey: content}, backup.Configurations)
+}
+
+func TestAgolloNamespaceConfigType(t *testing.T) {
+	backupfile, err := ioutil.TempFile("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(backupfile.Name())
+
+	var (
+		lock      sync.Mutex
+		requested []string
+	)
+	client := &mockApolloClient{
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			lock.Lock()
+			requested = append(requested, namespace)
+			lock.Unlock()
+
+			conf := Configurations{"name": namespace}
+			if namespace == "routes.yaml" {
+				conf = Configurations{ContentKey: "name: routes"}
+			}
+			return 200, &Config{NamespaceName: namespace, Configurations: conf, ReleaseKey: "1"}, nil
+		},
+	}
+
+	a, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		NamespaceConfigType("routes", ConfigTypeYAML),
+		PreloadNamespaces("application", "routes", "test.properties", "datasource.json"),
+		BackupFile(backupfile.Name()),
+	)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"application", "routes.yaml", "test", "datasource.json"}, requested)
+	assert.Equal(t, "routes", a.Get("name", WithNamespace("routes")))
+	assert.Equal(t, "routes", a.Get("name", WithNamespace("routes.yaml")))
+	assert.Equal(t, "test", a.Get("name", WithNamespace("test.properties")))
+	assert.Equal(t, "test", a.Get("name", WithNamespace("test")))
+}
+
+func TestAgolloShutdown(t *testing.T) {
+	backupfile, err := ioutil.TempFile("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(backupfile.Name())
+
+	client := &mockApolloClient{
+		getConfigServers: func(metaServerURL, appID string) (int, []ConfigServer, error) {
+			return 200, []ConfigServer{{HomePageURL: metaServerURL}}, nil
+		},
+	}
+	a, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		EnableSLB(true),
+		LongPollerInterval(time.Millisecond),
+		EnableHeartBeat(true),
+		HeartBeatInterval(time.Millisecond),
+		BackupFile(backupfile.Name()),
+	)
+	assert.Nil(t, err)
+
+	errorCh := a.Start()
+	watchCh := a.Watch()
+	watchNamespaceCh := a.WatchNamespace("application", nil)
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, a.Shutdown(ctx))
+
+	for _, ch := range []<-chan *ApolloResponse{watchCh, watchNamespaceCh, a.Watch(), a.WatchNamespace("other", nil)} {
+		select {
+		case _, ok := <-ch:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatal("watch channel should be closed after shutdown")
+		}
+	}
+	for range errorCh {
+	}
+
+	// Shutdown之后Rollback、Unfreeze等触发的发送不能panic
+	assert.NotPanics(t, func() {
+		a.(*agollo).sendWatchCh("application", Configurations{"foo": "1"}, Configurations{"foo": "2"})
+		a.(*agollo).sendErrorsCh("", nil, "application", fmt.Errorf("error"))
+	})
+
+	// 重复调用
+	assert.Nil(t, a.Shutdown(ctx))
+}
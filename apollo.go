@@ -1,6 +1,7 @@
 package agollo
 
 import (
+	"context"
 	"encoding/json"
 )
 
@@ -20,6 +21,48 @@ type ApolloClient interface {
 	GetConfigServers(metaServerURL, appID string) (int, []ConfigServer, error)
 }
 
+// ContextApolloClient 支持context.Context的ApolloClient，用于取消请求或者传递超时时间等信息，
+// 未实现该接口的ApolloClient在agollo中会忽略context
+type ContextApolloClient interface {
+	ApolloClient
+
+	NotificationsWithContext(ctx context.Context, configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error)
+
+	GetConfigsFromNonCacheWithContext(ctx context.Context, configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error)
+
+	GetConfigsFromCacheWithContext(ctx context.Context, configServerURL, appID, cluster, namespace string) (Configurations, error)
+
+	GetConfigServersWithContext(ctx context.Context, metaServerURL, appID string) (int, []ConfigServer, error)
+}
+
+// toContextApolloClient 将ApolloClient转换为ContextApolloClient，未实现ContextApolloClient时忽略context
+func toContextApolloClient(c ApolloClient) ContextApolloClient {
+	if cc, ok := c.(ContextApolloClient); ok {
+		return cc
+	}
+	return contextApolloClientAdapter{c}
+}
+
+type contextApolloClientAdapter struct {
+	ApolloClient
+}
+
+func (c contextApolloClientAdapter) NotificationsWithContext(_ context.Context, configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error) {
+	return c.Notifications(configServerURL, appID, clusterName, notifications)
+}
+
+func (c contextApolloClientAdapter) GetConfigsFromNonCacheWithContext(_ context.Context, configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+	return c.GetConfigsFromNonCache(configServerURL, appID, cluster, namespace, opts...)
+}
+
+func (c contextApolloClientAdapter) GetConfigsFromCacheWithContext(_ context.Context, configServerURL, appID, cluster, namespace string) (Configurations, error) {
+	return c.GetConfigsFromCache(configServerURL, appID, cluster, namespace)
+}
+
+func (c contextApolloClientAdapter) GetConfigServersWithContext(_ context.Context, metaServerURL, appID string) (int, []ConfigServer, error) {
+	return c.GetConfigServers(metaServerURL, appID)
+}
+
 type Notifications []Notification
 
 func (n Notifications) String() string {
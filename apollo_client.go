@@ -1,6 +1,7 @@
 package agollo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -28,9 +29,11 @@ type Doer interface {
 type apolloClient struct {
 	Doer          Doer
 	IP            string
-	ConfigType    string // 默认properties不需要在namespace后加后缀名，其他情况例如application.json {xml,yml,yaml,json,...}
+	ConfigType    string // 默认properties不需要在namespace后加后缀名，其他情况例如application.json {xml,yml,yaml,json,...}，仅对不带后缀名的namespace生效
 	AccessKey     string
 	SignatureFunc SignatureFunc
+	RetryPolicy   RetryPolicy // 非长轮询接口的重试策略，默认不重试
+	DataCenter    string      // 所在的数据中心，设置后服务端会在集群中找不到namespace时回退到数据中心的同名集群
 }
 
 func NewApolloClient(opts ...ApolloClientOption) ApolloClient {
@@ -55,7 +58,11 @@ func (c *apolloClient) Apply(opts ...ApolloClientOption) {
 	}
 }
 
-func (c *apolloClient) Notifications(configServerURL, appID, cluster string, notifications []Notification) (status int, result []Notification, err error) {
+func (c *apolloClient) Notifications(configServerURL, appID, cluster string, notifications []Notification) (int, []Notification, error) {
+	return c.NotificationsWithContext(context.Background(), configServerURL, appID, cluster, notifications)
+}
+
+func (c *apolloClient) NotificationsWithContext(ctx context.Context, configServerURL, appID, cluster string, notifications []Notification) (status int, result []Notification, err error) {
 	if len(notifications) == 0 {
 		return 0, []Notification{}, nil
 	}
@@ -64,7 +71,7 @@ func (c *apolloClient) Notifications(configServerURL, appID, cluster string, not
 		url.QueryEscape(appID),
 		url.QueryEscape(cluster),
 		url.QueryEscape(Notifications(notifications).String()),
-	)
+	) + c.dataCenterQuery()
 	apiURL := fmt.Sprintf("%s%s", configServerURL, requestURI)
 
 	headers := c.SignatureFunc(&SignatureContext{
@@ -74,81 +81,92 @@ func (c *apolloClient) Notifications(configServerURL, appID, cluster string, not
 		AppID:           appID,
 		Cluster:         cluster,
 	})
-	status, err = c.do("GET", apiURL, headers, &result)
+	status, err = c.do(ctx, "GET", apiURL, headers, &result)
 	return
 }
 
-func (c *apolloClient) GetConfigsFromNonCache(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (status int, config *Config, err error) {
+func (c *apolloClient) GetConfigsFromNonCache(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+	return c.GetConfigsFromNonCacheWithContext(context.Background(), configServerURL, appID, cluster, namespace, opts...)
+}
+
+func (c *apolloClient) GetConfigsFromNonCacheWithContext(ctx context.Context, configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (status int, config *Config, err error) {
 	var options = NotificationsOptions{}
 	for _, opt := range opts {
 		opt(&options)
 	}
 
-	configServerURL = normalizeURL(configServerURL)
 	requestURI := fmt.Sprintf("/configs/%s/%s/%s?releaseKey=%s&ip=%s",
 		url.QueryEscape(appID),
 		url.QueryEscape(cluster),
 		url.QueryEscape(c.getNamespace(namespace)),
 		options.ReleaseKey,
 		c.IP,
-	)
-	apiURL := fmt.Sprintf("%s%s", configServerURL, requestURI)
+	) + c.dataCenterQuery()
 
-	headers := c.SignatureFunc(&SignatureContext{
-		ConfigServerURL: configServerURL,
-		RequestURI:      requestURI,
-		AccessKey:       c.AccessKey,
-		AppID:           appID,
-		Cluster:         cluster,
-	})
 	config = new(Config)
-	status, err = c.do("GET", apiURL, headers, config)
+	status, err = c.doWithRetry(ctx, configServerURL, requestURI, appID, cluster, config)
 	return
 
 }
 
-func (c *apolloClient) GetConfigsFromCache(configServerURL, appID, cluster, namespace string) (config Configurations, err error) {
-	configServerURL = normalizeURL(configServerURL)
+func (c *apolloClient) GetConfigsFromCache(configServerURL, appID, cluster, namespace string) (Configurations, error) {
+	return c.GetConfigsFromCacheWithContext(context.Background(), configServerURL, appID, cluster, namespace)
+}
+
+func (c *apolloClient) GetConfigsFromCacheWithContext(ctx context.Context, configServerURL, appID, cluster, namespace string) (config Configurations, err error) {
 	requestURI := fmt.Sprintf("/configfiles/json/%s/%s/%s?ip=%s",
 		url.QueryEscape(appID),
 		url.QueryEscape(cluster),
 		url.QueryEscape(c.getNamespace(namespace)),
 		c.IP,
-	)
-	apiURL := fmt.Sprintf("%s%s", configServerURL, requestURI)
+	) + c.dataCenterQuery()
 
-	headers := c.SignatureFunc(&SignatureContext{
-		ConfigServerURL: configServerURL,
-		RequestURI:      requestURI,
-		AccessKey:       c.AccessKey,
-		AppID:           appID,
-		Cluster:         cluster,
-	})
 	config = make(Configurations)
-	_, err = c.do("GET", apiURL, headers, config)
+	_, err = c.doWithRetry(ctx, configServerURL, requestURI, appID, cluster, config)
 	return
 }
 
 func (c *apolloClient) GetConfigServers(metaServerURL, appID string) (int, []ConfigServer, error) {
-	metaServerURL = normalizeURL(metaServerURL)
+	return c.GetConfigServersWithContext(context.Background(), metaServerURL, appID)
+}
+
+func (c *apolloClient) GetConfigServersWithContext(ctx context.Context, metaServerURL, appID string) (int, []ConfigServer, error) {
 	requestURI := fmt.Sprintf("/services/config?id=%s&appId=%s", c.IP, appID)
-	apiURL := fmt.Sprintf("%s%s", metaServerURL, requestURI)
 
-	headers := c.SignatureFunc(&SignatureContext{
-		ConfigServerURL: metaServerURL,
-		RequestURI:      requestURI,
-		AccessKey:       c.AccessKey,
-		AppID:           appID,
-		Cluster:         "",
-	})
 	var cfs []ConfigServer
-	status, err := c.do("GET", apiURL, headers, &cfs)
+	status, err := c.doWithRetry(ctx, metaServerURL, requestURI, appID, "", &cfs)
 	return status, cfs, err
 }
 
-func (c *apolloClient) do(method, url string, headers map[string]string, v interface{}) (status int, err error) {
+// doWithRetry 按照RetryPolicy重试GET请求，长轮询的notifications接口不重试，由SchedulePolicy控制失败后的等待时间。
+// ctx中带有withServerSelector设置的selector时，每次重试前通过selector选择新的server并重新签名
+func (c *apolloClient) doWithRetry(ctx context.Context, serverURL, requestURI, appID, cluster string, v interface{}) (int, error) {
+	var (
+		selector = serverSelectorFromContext(ctx)
+		status   int
+		err      error
+	)
+	return c.RetryPolicy.retry(ctx, func(attempt int) (int, error) {
+		if attempt > 1 && selector != nil {
+			serverURL = selector(serverURL, status, err)
+		}
+		normalizedURL := normalizeURL(serverURL)
+
+		headers := c.SignatureFunc(&SignatureContext{
+			ConfigServerURL: normalizedURL,
+			RequestURI:      requestURI,
+			AccessKey:       c.AccessKey,
+			AppID:           appID,
+			Cluster:         cluster,
+		})
+		status, err = c.do(ctx, "GET", normalizedURL+requestURI, headers, v)
+		return status, err
+	})
+}
+
+func (c *apolloClient) do(ctx context.Context, method, url string, headers map[string]string, v interface{}) (status int, err error) {
 	var req *http.Request
-	req, err = http.NewRequest(method, url, nil)
+	req, err = http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return
 	}
@@ -164,20 +182,32 @@ func (c *apolloClient) do(method, url string, headers map[string]string, v inter
 	}
 
 	if status == http.StatusOK {
-		err = json.Unmarshal(body, v)
+		if uerr := json.Unmarshal(body, v); uerr != nil {
+			err = &decodeError{uerr}
+		}
 	}
 	return
 }
 
 // 配置文件有多种格式，例如：properties、xml、yml、yaml、json等。同样Namespace也具有这些格式。在Portal UI中可以看到“application”的Namespace上有一个“properties”标签，表明“application”是properties格式的。
 // 如果使用Http接口直接调用时，对应的namespace参数需要传入namespace的名字加上后缀名，如datasources.json。
+// namespace已经带有格式后缀名时不再追加ConfigType，避免出现datasources.json.json
 func (c *apolloClient) getNamespace(namespace string) string {
-	if c.ConfigType == "" || c.ConfigType == defaultConfigType {
+	if c.ConfigType == "" || c.ConfigType == defaultConfigType ||
+		getConfigTypeByExt(namespace) != "" {
 		return namespace
 	}
 	return namespace + "." + c.ConfigType
 }
 
+// dataCenterQuery configs、configfiles、notifications接口的dataCenter参数
+func (c *apolloClient) dataCenterQuery() string {
+	if c.DataCenter == "" {
+		return ""
+	}
+	return "&dataCenter=" + url.QueryEscape(c.DataCenter)
+}
+
 func getLocalIP() string {
 	addrs, err := net.InterfaceAddrs()
 	if err != nil {
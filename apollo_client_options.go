@@ -14,6 +14,10 @@ func WithIP(ip string) ApolloClientOption {
 	}
 }
 
+// WithConfigType 为所有不带后缀名的namespace追加统一的后缀名
+//
+// Deprecated: 同一个client无法混用多种格式的namespace，请使用agollo.NamespaceConfigType
+// 为每个namespace单独指定格式，或者直接使用带后缀名的namespace，例如: datasource.json
 func WithConfigType(configType string) ApolloClientOption {
 	return func(a *apolloClient) {
 		a.ConfigType = configType
@@ -31,3 +35,17 @@ func WithSignatureFunc(sf SignatureFunc) ApolloClientOption {
 		a.SignatureFunc = sf
 	}
 }
+
+// WithRetryPolicy 设置configs、configfiles、services接口的重试策略，例如：DefaultRetryPolicy()
+func WithRetryPolicy(p RetryPolicy) ApolloClientOption {
+	return func(a *apolloClient) {
+		a.RetryPolicy = p
+	}
+}
+
+// WithDataCenter 请求configs、configfiles、notifications接口时携带dataCenter参数
+func WithDataCenter(dataCenter string) ApolloClientOption {
+	return func(a *apolloClient) {
+		a.DataCenter = dataCenter
+	}
+}
@@ -0,0 +1,104 @@
+package agollo
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockDoer struct {
+	do func(*http.Request) (*http.Response, error)
+}
+
+func (d *mockDoer) Do(req *http.Request) (*http.Response, error) {
+	return d.do(req)
+}
+
+func newMockResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+	}
+}
+
+func TestApolloClientWithContext(t *testing.T) {
+	client := NewApolloClient(WithDoer(&mockDoer{
+		do: func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		},
+	})).(ContextApolloClient)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, _, err := client.NotificationsWithContext(ctx, "localhost:8080", "test", "default",
+		[]Notification{{NamespaceName: "application", NotificationID: -1}})
+	assert.Error(t, err)
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+}
+
+func TestAgolloStopCancelsLongPoll(t *testing.T) {
+	backupfile, err := ioutil.TempFile("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(backupfile.Name())
+
+	var (
+		polling  = make(chan struct{}, 1)
+		canceled = make(chan struct{}, 1)
+	)
+	client := NewApolloClient(WithDoer(&mockDoer{
+		do: func(req *http.Request) (*http.Response, error) {
+			if strings.HasPrefix(req.URL.Path, "/configs/") {
+				return newMockResponse(200, `{"namespaceName":"application","configurations":{"foo":"bar"},"releaseKey":"1"}`), nil
+			}
+			// 初始化时获取notificationID
+			if strings.Contains(req.URL.Query().Get("notifications"), `"notificationId":-1`) {
+				return newMockResponse(200, `[{"namespaceName":"application","notificationId":1}]`), nil
+			}
+
+			select {
+			case polling <- struct{}{}:
+			default:
+			}
+			<-req.Context().Done()
+			select {
+			case canceled <- struct{}{}:
+			default:
+			}
+			return nil, req.Context().Err()
+		},
+	}))
+
+	a, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		PreloadNamespaces("application"),
+		LongPollerInterval(time.Millisecond),
+		BackupFile(backupfile.Name()),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", a.Get("foo"))
+
+	a.Start()
+	select {
+	case <-polling:
+	case <-time.After(time.Second):
+		t.Fatal("long poll should be started")
+	}
+
+	a.Stop()
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("long poll should be canceled after stop")
+	}
+}
@@ -0,0 +1,401 @@
+package agollo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBackupNotFound = errors.New("backup not found")
+	ErrBackupChecksum = errors.New("backup checksum mismatch")
+	ErrBackupExpired  = errors.New("backup expired")
+)
+
+// backupFileVersion 备份文件的格式版本，旧版本的备份文件为map[string]Configurations
+const backupFileVersion = 2
+
+// Backup namespace的原始配置及元信息
+type Backup struct {
+	Namespace      string         `json:"namespace"`
+	AppID          string         `json:"appId"`
+	Cluster        string         `json:"cluster"`
+	ReleaseKey     string         `json:"releaseKey"`
+	FetchedAt      time.Time      `json:"fetchedAt"` // 从apollo获取配置的时间，旧版本的备份为零值
+	Checksum       string         `json:"checksum"`  // Configurations的sha256，旧版本的备份为空
+	Configurations Configurations `json:"configurations"`
+}
+
+// NewBackup 创建备份并计算Checksum
+func NewBackup(namespace, appID, cluster, releaseKey string, config Configurations) *Backup {
+	b := &Backup{
+		Namespace:      namespace,
+		AppID:          appID,
+		Cluster:        cluster,
+		ReleaseKey:     releaseKey,
+		FetchedAt:      time.Now(),
+		Configurations: config,
+	}
+	b.Checksum, _ = b.checksum()
+	return b
+}
+
+// Age 备份距今的时间，旧版本的备份没有获取时间返回-1
+func (b *Backup) Age() time.Duration {
+	if b.FetchedAt.IsZero() {
+		return -1
+	}
+	return time.Since(b.FetchedAt)
+}
+
+// Verify 校验Checksum，旧版本没有Checksum的备份不校验
+func (b *Backup) Verify() error {
+	if b.Checksum == "" {
+		return nil
+	}
+
+	checksum, err := b.checksum()
+	if err != nil {
+		return err
+	}
+	if checksum != b.Checksum {
+		return ErrBackupChecksum
+	}
+	return nil
+}
+
+func (b *Backup) checksum() (string, error) {
+	// json.Marshal对map的key排序，结果是稳定的
+	data, err := json.Marshal(b.Configurations)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// BackupStore 备份namespace的原始配置，用于连接apollo失败时的容灾
+type BackupStore interface {
+	// Load 读取namespace的备份，不存在时返回ErrBackupNotFound
+	Load(namespace string) (*Backup, error)
+	Save(namespace string, backup *Backup) error
+	Delete(namespace string) error
+}
+
+type BackupStoreOptions struct {
+	KeyProvider    KeyProvider // 设置后备份使用AES-GCM加密，并且文件权限为0600
+	AllowPlaintext bool        // 设置KeyProvider后仍然允许读取未加密的备份，用于迁移开启加密前的备份，默认：false
+}
+
+type BackupStoreOption func(*BackupStoreOptions)
+
+// EncryptBackup 使用kp提供的密钥加密备份文件，读取时自动解密，
+// 未加密的备份会返回ErrBackupNotEncrypted，迁移旧备份时请同时设置AllowPlaintextBackup
+func EncryptBackup(kp KeyProvider) BackupStoreOption {
+	return func(o *BackupStoreOptions) {
+		o.KeyProvider = kp
+	}
+}
+
+// AllowPlaintextBackup 开启加密后仍然允许读取未加密的旧备份，写入时会重新加密，迁移完成后请去掉
+func AllowPlaintextBackup() BackupStoreOption {
+	return func(o *BackupStoreOptions) {
+		o.AllowPlaintext = true
+	}
+}
+
+func newBackupStoreOptions(opts ...BackupStoreOption) BackupStoreOptions {
+	var options BackupStoreOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+func (o BackupStoreOptions) readFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrBackupNotFound
+		}
+		return nil, err
+	}
+	// 空文件视为没有备份，例如预先创建的备份文件
+	if len(data) == 0 {
+		return nil, ErrBackupNotFound
+	}
+	return decryptBackup(o.KeyProvider, data, o.AllowPlaintext)
+}
+
+func (o BackupStoreOptions) writeFile(path string, data []byte) (err error) {
+	if o.KeyProvider == nil {
+		return writeFileAtomic(path, data, 0644)
+	}
+
+	if data, err = encryptBackup(o.KeyProvider, data); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// fileBackupStore 所有namespace的备份保存在同一个json文件中，默认的备份方式，
+// 多个进程共享同一个备份文件时通过文件锁保证读取-修改-写入的完整性
+type fileBackupStore struct {
+	path string
+	opts BackupStoreOptions
+	lock sync.RWMutex
+}
+
+// NewFileBackupStore 所有namespace的备份保存在同一个json文件中，与BackupFile的格式相同
+func NewFileBackupStore(path string, opts ...BackupStoreOption) BackupStore {
+	return &fileBackupStore{path: path, opts: newBackupStoreOptions(opts...)}
+}
+
+func (s *fileBackupStore) Load(namespace string) (backup *Backup, err error) {
+	if _, err = os.Stat(s.path); os.IsNotExist(err) {
+		return nil, ErrBackupNotFound
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	err = s.withFileLock(false, func() error {
+		file, err := s.loadFile()
+		if err != nil {
+			return err
+		}
+
+		var found bool
+		backup, found = file.Backups[namespace]
+		if !found {
+			return ErrBackupNotFound
+		}
+		return nil
+	})
+	return backup, err
+}
+
+func (s *fileBackupStore) Save(namespace string, backup *Backup) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.withFileLock(true, func() error {
+		file, err := s.loadFileForUpdate()
+		if err != nil {
+			return err
+		}
+		if file.Backups == nil {
+			file.Backups = map[string]*Backup{}
+		}
+		file.Backups[namespace] = backup
+		return s.saveFile(file)
+	})
+}
+
+func (s *fileBackupStore) Delete(namespace string) error {
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.withFileLock(true, func() error {
+		file, err := s.loadFile()
+		if err == ErrBackupNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, found := file.Backups[namespace]; !found {
+			return nil
+		}
+		delete(file.Backups, namespace)
+		return s.saveFile(file)
+	})
+}
+
+func (s *fileBackupStore) withFileLock(exclusive bool, f func() error) error {
+	return withFileLock(s.path, exclusive, f)
+}
+
+// withFileLock 在path对应的跨进程文件锁内执行f，exclusive为false时为共享锁
+func withFileLock(path string, exclusive bool, f func() error) error {
+	l, err := newFileLock(path)
+	if err != nil {
+		return err
+	}
+
+	if exclusive {
+		err = l.Lock()
+	} else {
+		err = l.RLock()
+	}
+	if err != nil {
+		l.f.Close()
+		return err
+	}
+	defer l.Unlock()
+
+	return f()
+}
+
+// backupFile 备份文件的格式
+type backupFile struct {
+	Version int                  `json:"version"`
+	Backups map[string]*Backup   `json:"backups"`           // key: namespace
+	History map[string][]*Backup `json:"history,omitempty"` // key: namespace
+}
+
+func (s *fileBackupStore) loadFile() (*backupFile, error) {
+	data, err := s.opts.readFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &backupFile{}
+	if err = json.Unmarshal(data, file); err == nil && file.Version >= backupFileVersion {
+		return file, nil
+	}
+
+	// 兼容旧版本的备份文件
+	legacy := map[string]Configurations{}
+	if err = json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+
+	file = &backupFile{Backups: make(map[string]*Backup, len(legacy))}
+	for namespace, config := range legacy {
+		file.Backups[namespace] = &Backup{Namespace: namespace, Configurations: config}
+	}
+	return file, nil
+}
+
+// loadFileForUpdate 读取备份文件用于修改，文件不存在时返回空的backupFile，
+// 文件无法读取(损坏、未加密或者密钥错误)时移动到path.corrupt后重新开始，避免之后的写入一直失败
+func (s *fileBackupStore) loadFileForUpdate() (*backupFile, error) {
+	file, err := s.loadFile()
+	if err == nil {
+		return file, nil
+	}
+	if err != ErrBackupNotFound {
+		if err = moveCorruptFile(s.path); err != nil {
+			return nil, err
+		}
+	}
+	return &backupFile{}, nil
+}
+
+func (s *fileBackupStore) saveFile(file *backupFile) error {
+	file.Version = backupFileVersion
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return s.opts.writeFile(s.path, data)
+}
+
+// dirBackupStore 每个namespace的备份保存在目录下的单独文件中，
+// 适合多个进程(例如sidecar)共享同一个目录
+type dirBackupStore struct {
+	dir  string
+	opts BackupStoreOptions
+}
+
+// NewDirBackupStore 每个namespace的备份保存在dir目录下以namespace命名的json文件中
+func NewDirBackupStore(dir string, opts ...BackupStoreOption) BackupStore {
+	return &dirBackupStore{dir: dir, opts: newBackupStoreOptions(opts...)}
+}
+
+func (s *dirBackupStore) path(namespace string) string {
+	return filepath.Join(s.dir, url.PathEscape(namespace))
+}
+
+func (s *dirBackupStore) Load(namespace string) (*Backup, error) {
+	data, err := s.opts.readFile(s.path(namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	backup := &Backup{}
+	if err = json.Unmarshal(data, backup); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+func (s *dirBackupStore) Save(namespace string, backup *Backup) error {
+	data, err := json.Marshal(backup)
+	if err != nil {
+		return err
+	}
+	return s.opts.writeFile(s.path(namespace), data)
+}
+
+func (s *dirBackupStore) Delete(namespace string) error {
+	err := os.Remove(s.path(namespace))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// memoryBackupStore 备份保存在内存中，一般用于测试
+type memoryBackupStore struct {
+	backup sync.Map // key: namespace value: Backup
+
+	historyLock sync.Mutex
+	history     map[string][]*Backup // key: namespace
+}
+
+func NewMemoryBackupStore() BackupStore {
+	return &memoryBackupStore{}
+}
+
+func (s *memoryBackupStore) Load(namespace string) (*Backup, error) {
+	v, found := s.backup.Load(namespace)
+	if !found {
+		return nil, ErrBackupNotFound
+	}
+	backup := v.(Backup)
+	backup.Configurations = copyConfigurations(backup.Configurations)
+	return &backup, nil
+}
+
+func (s *memoryBackupStore) Save(namespace string, backup *Backup) error {
+	b := *backup
+	b.Configurations = copyConfigurations(backup.Configurations)
+	s.backup.Store(namespace, b)
+	return nil
+}
+
+func (s *memoryBackupStore) Delete(namespace string) error {
+	s.backup.Delete(namespace)
+	return nil
+}
+
+func copyConfigurations(config Configurations) Configurations {
+	c := make(Configurations, len(config))
+	for k, v := range config {
+		c[k] = v
+	}
+	return c
+}
+
+func mkdirAll(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		err = os.MkdirAll(dir, 0755)
+		if err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	return nil
+}
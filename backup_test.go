@@ -0,0 +1,277 @@
+package agollo
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "agollo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stores := map[string]BackupStore{
+		"file":   NewFileBackupStore(filepath.Join(dir, "file", ".agollo")),
+		"dir":    NewDirBackupStore(filepath.Join(dir, "dir")),
+		"memory": NewMemoryBackupStore(),
+	}
+
+	application := NewBackup("application", "test", "default", "1", Configurations{"foo": "bar"})
+	datasource := NewBackup("a/b.json", "test", "default", "2", Configurations{"content": "{}"})
+	for name, store := range stores {
+		_, err := store.Load("application")
+		assert.Equal(t, ErrBackupNotFound, err, name)
+		assert.Nil(t, store.Delete("application"), name)
+
+		assert.Nil(t, store.Save("application", application), name)
+		assert.Nil(t, store.Save("a/b.json", datasource), name)
+
+		backup, err := store.Load("application")
+		assert.Nil(t, err, name)
+		assert.Equal(t, application.Configurations, backup.Configurations, name)
+		assert.Equal(t, "1", backup.ReleaseKey, name)
+		assert.True(t, application.FetchedAt.Equal(backup.FetchedAt), name)
+		assert.Nil(t, backup.Verify(), name)
+
+		backup, err = store.Load("a/b.json")
+		assert.Nil(t, err, name)
+		assert.Equal(t, datasource.Configurations, backup.Configurations, name)
+
+		assert.Nil(t, store.Delete("application"), name)
+		_, err = store.Load("application")
+		assert.Equal(t, ErrBackupNotFound, err, name)
+
+		_, err = store.Load("a/b.json")
+		assert.Nil(t, err, name)
+	}
+}
+
+func TestFileBackupStoreLegacyFormat(t *testing.T) {
+	backupfile, err := ioutil.TempFile("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(backupfile.Name())
+
+	_, err = backupfile.WriteString(`{"application":{"foo":"bar"}}`)
+	assert.Nil(t, err)
+	assert.Nil(t, backupfile.Close())
+
+	store := NewFileBackupStore(backupfile.Name())
+	backup, err := store.Load("application")
+	assert.Nil(t, err)
+	assert.Equal(t, &Backup{Namespace: "application", Configurations: Configurations{"foo": "bar"}}, backup)
+	assert.Nil(t, backup.Verify())
+	assert.Equal(t, time.Duration(-1), backup.Age())
+
+	// 写入时升级为新的格式，并保留旧的备份
+	assert.Nil(t, store.Save("test", NewBackup("test", "test", "default", "1", Configurations{})))
+	backup, err = store.Load("application")
+	assert.Nil(t, err)
+	assert.Equal(t, Configurations{"foo": "bar"}, backup.Configurations)
+}
+
+func TestBackupVerify(t *testing.T) {
+	backup := NewBackup("application", "test", "default", "1", Configurations{"foo": "bar"})
+	assert.NotEmpty(t, backup.Checksum)
+	assert.Nil(t, backup.Verify())
+
+	backup.Configurations["foo"] = "baz"
+	assert.Equal(t, ErrBackupChecksum, backup.Verify())
+}
+
+func TestFileBackupStoreConcurrentSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "agollo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 不同的store实例模拟多个进程共享同一个备份文件
+	path := filepath.Join(dir, ".agollo")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			namespace := strconv.Itoa(i)
+			backup := NewBackup(namespace, "test", "default", namespace, Configurations{"foo": namespace})
+			assert.Nil(t, NewFileBackupStore(path).Save(namespace, backup))
+		}(i)
+	}
+	wg.Wait()
+
+	store := NewFileBackupStore(path)
+	for i := 0; i < 20; i++ {
+		namespace := strconv.Itoa(i)
+		backup, err := store.Load(namespace)
+		assert.Nil(t, err)
+		assert.Equal(t, Configurations{"foo": namespace}, backup.Configurations)
+	}
+
+	// 不残留临时文件
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmp*"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 0)
+}
+
+func TestAgolloWithBackupStore(t *testing.T) {
+	var releaseKeys []string
+	client := &mockApolloClient{
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			var options NotificationsOptions
+			for _, opt := range opts {
+				opt(&options)
+			}
+			releaseKeys = append(releaseKeys, options.ReleaseKey)
+			return 500, nil, nil
+		},
+	}
+
+	backup := NewBackup("application", "test", "default", "20200101", Configurations{"foo": "bar"})
+	backup.FetchedAt = time.Now().Add(-time.Hour)
+	store := NewMemoryBackupStore()
+	assert.Nil(t, store.Save("application", backup))
+
+	a, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		PreloadNamespaces("application"),
+		WithBackupStore(store),
+		FailTolerantOnBackupExists(),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", a.Get("foo"))
+
+	status := a.Status()["application"]
+	assert.Equal(t, NamespaceSourceBackup, status.Source)
+	assert.Equal(t, "20200101", status.ReleaseKey)
+	assert.True(t, status.Age() >= time.Hour)
+
+	// 恢复备份时的release_key
+	a.(*agollo).reloadNamespace(context.Background(), "application")
+	assert.Equal(t, []string{"", "20200101"}, releaseKeys)
+
+	// 超过BackupMaxAge的备份不再使用
+	a, err = New("localhost:8080", "test",
+		WithApolloClient(client),
+		PreloadNamespaces("application"),
+		WithBackupStore(store),
+		FailTolerantOnBackupExists(),
+		BackupMaxAge(time.Minute),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, "", a.Get("foo"))
+	assert.Equal(t, NamespaceSourceEmpty, a.Status()["application"].Source)
+
+	// 没有可用的ConfigServer时同样使用备份
+	a, err = New("localhost:8080", "test",
+		WithApolloClient(client),
+		WithBalancer(NewRoundRobin(nil)),
+		PreloadNamespaces("application"),
+		WithBackupStore(store),
+		FailTolerantOnBackupExists(),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", a.Get("foo"))
+	status = a.Status()["application"]
+	assert.Equal(t, NamespaceSourceBackup, status.Source)
+	assert.Equal(t, ErrNoConfigServerAvailable, status.LastError)
+}
+
+func TestAgolloUnreadableBackupFile(t *testing.T) {
+	key := StaticKey([]byte("0123456789abcdef0123456789abcdef"))
+	plaintext := func(path string) {
+		backup := NewBackup("application", "test", "default", "0", Configurations{"timeout": "3s"})
+		assert.Nil(t, NewFileBackupStore(path).Save("application", backup))
+	}
+	corrupt := func(path string) {
+		assert.Nil(t, ioutil.WriteFile(path, []byte("{corrupt"), 0644))
+	}
+
+	tests := []struct {
+		name  string
+		write func(path string)
+		opts  []Option
+	}{
+		{"corrupt", corrupt, nil},
+		{"plaintext with encryption", plaintext, []Option{BackupEncryption(key)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "agollo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, ".agollo")
+			test.write(path)
+
+			var (
+				lock           sync.Mutex
+				config         = &Config{NamespaceName: "application", Configurations: Configurations{"timeout": "2s"}, ReleaseKey: "1"}
+				notificationID = 1
+			)
+			client := &mockApolloClient{
+				notifications: func(configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error) {
+					lock.Lock()
+					defer lock.Unlock()
+					return 200, []Notification{{NamespaceName: "application", NotificationID: notificationID}}, nil
+				},
+				getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+					lock.Lock()
+					defer lock.Unlock()
+					return 200, config, nil
+				},
+			}
+
+			ag, err := New("localhost:8080", "test",
+				append([]Option{WithApolloClient(client), BackupFile(path), PreloadNamespaces("application")}, test.opts...)...,
+			)
+			assert.Nil(t, err)
+			a := ag.(*agollo)
+
+			// 初始化时无法读取的备份文件被移动到.corrupt，重新写入新的备份
+			_, err = os.Stat(path + ".corrupt")
+			assert.Nil(t, err)
+			backup, err := a.opts.BackupStore.Load("application")
+			assert.Nil(t, err)
+			assert.Equal(t, Configurations{"timeout": "2s"}, backup.Configurations)
+
+			var (
+				conf    testBindConfig
+				changed = make(chan struct{}, 1)
+			)
+			_, err = a.Bind("application", &conf, OnBindChange(func(oldValue, newValue interface{}) {
+				changed <- struct{}{}
+			}))
+			assert.Nil(t, err)
+
+			// 运行过程中备份文件再次无法读取，变更依然通知并且更新NotificationID
+			test.write(path)
+			lock.Lock()
+			config = &Config{NamespaceName: "application", Configurations: Configurations{"timeout": "5s"}, ReleaseKey: "2"}
+			notificationID = 2
+			lock.Unlock()
+
+			assert.Nil(t, a.longPoll(context.Background()))
+			assert.Equal(t, "5s", a.Get("timeout"))
+			assert.Len(t, changed, 1)
+			id, _ := a.notificationMap.Load("application")
+			assert.Equal(t, 2, id)
+			backup, err = a.opts.BackupStore.Load("application")
+			assert.Nil(t, err)
+			assert.Equal(t, Configurations{"timeout": "5s"}, backup.Configurations)
+		})
+	}
+}
@@ -1,9 +1,10 @@
 package agollo
 
 import (
+	"context"
 	"errors"
 	"math/rand"
-	"os"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,49 +21,86 @@ type Balancer interface {
 	Stop()
 }
 
+// balancerShutdowner Balancer实现该接口时，Agollo.Shutdown会等待其启动的goroutine退出
+type balancerShutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
 type autoFetchBalancer struct {
-	appID             string
-	getConfigServers  GetConfigServersFunc
-	metaServerAddress string
+	appID               string
+	getConfigServers    getConfigServersWithContextFunc
+	probe               ProbeFunc
+	metaServerAddresses []string
+	metaServerIndex     uint32 // 上一次成功获取config server列表的meta server，下一次优先使用
 
 	logger Logger
 
-	mu sync.RWMutex
-	b  Balancer
+	mu     sync.RWMutex
+	b      Balancer
+	health *healthChecker // 在刷新config server列表时保留各实例的状态
 
-	stopCh chan struct{}
+	ctx      context.Context // Stop时取消正在进行中的请求
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
 }
 
 type GetConfigServersFunc func(metaServerURL, appID string) (int, []ConfigServer, error)
 
+type getConfigServersWithContextFunc func(ctx context.Context, metaServerURL, appID string) (int, []ConfigServer, error)
+
 func NewAutoFetchBalancer(configServerURL, appID string, getConfigServers GetConfigServersFunc,
-	refreshIntervalInSecond time.Duration, logger Logger) (Balancer, error) {
+	refreshIntervalInSecond time.Duration, logger Logger, opts ...HealthCheckOption) (Balancer, error) {
+
+	return newAutoFetchBalancer(configServerURL, appID,
+		func(_ context.Context, metaServerURL, appID string) (int, []ConfigServer, error) {
+			return getConfigServers(metaServerURL, appID)
+		},
+		refreshIntervalInSecond, logger, opts...)
+}
+
+func newAutoFetchBalancer(configServerURL, appID string, getConfigServers getConfigServersWithContextFunc,
+	refreshIntervalInSecond time.Duration, logger Logger, opts ...HealthCheckOption) (*autoFetchBalancer, error) {
 
 	if refreshIntervalInSecond <= time.Duration(0) {
 		refreshIntervalInSecond = defaultRefreshIntervalInSecond
 	}
 
 	b := &autoFetchBalancer{
-		appID:             appID,
-		getConfigServers:  getConfigServers,
-		metaServerAddress: getMetaServerAddress(configServerURL), // Meta Server只是一个逻辑角色，在部署时和Config Service是在一个JVM进程中的，所以IP、端口和Config Service一致
-		logger:            logger,
-		stopCh:            make(chan struct{}),
-		b:                 NewRoundRobin([]string{configServerURL}),
+		appID:               appID,
+		getConfigServers:    getConfigServers,
+		metaServerAddresses: getMetaServerAddresses(configServerURL), // Meta Server只是一个逻辑角色，在部署时和Config Service是在一个JVM进程中的，所以IP、端口和Config Service一致
+		logger:              logger,
+		health:              newHealthChecker(opts...),
+	}
+	// 优先使用HealthCheckOptions中的Probe，未设置时通过/services/config接口探测
+	b.probe = b.health.opts.Probe
+	if b.probe == nil {
+		b.probe = configServerProbe(getConfigServers, appID)
 	}
+	// 只安装探测通过的config server，第一次刷新成功之前没有可用的config server
+	b.b = newRoundRobin(nil, b.health)
+	b.ctx, b.cancel = context.WithCancel(context.Background())
 
-	err := b.updateConfigServices()
+	css, err := b.getConfigServices()
 	if err != nil {
+		b.cancel()
+		b.health.stop()
 		return nil, err
 	}
+	// 没有config server探测通过时以空列表启动，由定时刷新重试，期间可以使用备份容灾
+	_ = b.installConfigServices(css)
 
+	b.wg.Add(1)
 	go func() {
+		defer b.wg.Done()
+
 		ticker := time.NewTicker(refreshIntervalInSecond)
 		defer ticker.Stop()
 
 		for {
 			select {
-			case <-b.stopCh:
+			case <-b.ctx.Done():
 				return
 			case <-ticker.C:
 				_ = b.updateConfigServices()
@@ -73,88 +111,121 @@ func NewAutoFetchBalancer(configServerURL, appID string, getConfigServers GetCon
 	return b, nil
 }
 
-/*
-参考了java客户端实现
-目前实现方式:
-0. 客户端显式传入ConfigServerURL
-1. 读取APOLLO_META环境变量
-2. 默认如果没有提供meta服务地址默认使用(http://apollo.meta)
-
-未实现:
-读取properties的逻辑
-https://github.com/ctripcorp/apollo/blob/7545bd3cd7d4b996d7cda50f53cd4aa8b045a2bb/apollo-core/src/main/java/com/ctrip/framework/apollo/core/MetaDomainConsts.java#L27
-*/
-func getMetaServerAddress(configServerURL string) string {
+// getMetaServerAddresses meta server地址的来源见getMetaServerURL，多个meta server以逗号分隔，
+// 获取config server列表时依次尝试，都为空时默认使用(http://apollo.meta)
+func getMetaServerAddresses(configServerURL string) []string {
 	var urls []string
-	for _, url := range []string{
-		configServerURL,
-		os.Getenv("APOLLO_META"),
-	} {
-		if url != "" {
-			urls = splitCommaSeparatedURL(url)
-			break
-		}
+	if url := getMetaServerURL(configServerURL, ""); url != "" {
+		urls = splitCommaSeparatedURL(url)
 	}
 
-	if len(urls) > 0 {
-		return normalizeURL(urls[rand.Intn(len(urls))])
+	if len(urls) == 0 {
+		return []string{defaultMetaURL}
 	}
 
-	return defaultMetaURL
+	// 打乱顺序，避免所有客户端优先访问同一个meta server
+	rand.Shuffle(len(urls), func(i, j int) {
+		urls[i], urls[j] = urls[j], urls[i]
+	})
+	return urls
 }
 
+// updateConfigServices 从meta server获取config server列表，只使用探测通过的config server，
+// meta server全部不可用或者没有config server探测通过时返回错误，并保留上一次的列表
 func (b *autoFetchBalancer) updateConfigServices() error {
 	css, err := b.getConfigServices()
 	if err != nil {
 		return err
 	}
+	return b.installConfigServices(css)
+}
 
-	var urls []string
-	for _, url := range css {
-		//check whether /services/config is accessible
-		status, _, err := b.getConfigServers(url, b.appID)
-		if err != nil {
-			continue
-		}
-
-		// select the first available meta server
-		// https://github.com/ctripcorp/apollo/blob/7545bd3cd7d4b996d7cda50f53cd4aa8b045a2bb/apollo-core/src/main/java/com/ctrip/framework/apollo/core/MetaDomainConsts.java#L166
-		// 这里这段逻辑是参考java客户端，直接选了第一个可用的meta server
-		if 200 <= status && status <= 399 {
-			urls = append(urls, url)
-			break
-		}
-	}
-
+// installConfigServices 只安装css中探测通过的config server，都没有通过时返回错误并保留上一次的列表
+func (b *autoFetchBalancer) installConfigServices(css []string) error {
+	urls := b.probeConfigServices(css)
 	if len(urls) == 0 {
-		return nil
+		b.logger.Log(
+			"[Agollo]", "",
+			"AppID", b.appID,
+			"ConfigServices", css,
+			"Error", ErrNoConfigServerAvailable,
+		)
+		return ErrNoConfigServerAvailable
 	}
 
 	b.mu.Lock()
-	b.b = NewRoundRobin(css)
+	b.b = newRoundRobin(urls, b.health)
 	b.mu.Unlock()
+	b.health.retain(urls)
 
 	return nil
 }
 
+// getConfigServices 依次尝试所有meta server，从上一次成功的meta server开始
 func (b *autoFetchBalancer) getConfigServices() ([]string, error) {
-	_, css, err := b.getConfigServers(b.metaServerAddress, b.appID)
-	if err != nil {
-		b.logger.Log(
-			"[Agollo]", "",
-			"AppID", b.appID,
-			"MetaServerAddress", b.metaServerAddress,
-			"Error", err,
-		)
-		return nil, err
+	var (
+		start   = int(atomic.LoadUint32(&b.metaServerIndex))
+		lastErr error
+	)
+	for i := 0; i < len(b.metaServerAddresses); i++ {
+		idx := (start + i) % len(b.metaServerAddresses)
+		metaServerAddress := b.metaServerAddresses[idx]
+
+		status, css, err := b.getConfigServers(b.ctx, metaServerAddress, b.appID)
+		if err == nil && status != http.StatusOK {
+			err = statusError(status, nil)
+		}
+		if err == nil && len(css) == 0 {
+			err = ErrNoConfigServerAvailable
+		}
+		if err != nil {
+			b.logger.Log(
+				"[Agollo]", "",
+				"AppID", b.appID,
+				"MetaServerAddress", metaServerAddress,
+				"Error", err,
+			)
+			lastErr = err
+			if b.ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		atomic.StoreUint32(&b.metaServerIndex, uint32(idx))
+
+		var urls []string
+		for _, cs := range css {
+			urls = append(urls, normalizeURL(cs.HomePageURL))
+		}
+		return urls, nil
 	}
 
-	var urls []string
-	for _, cs := range css {
-		urls = append(urls, normalizeURL(cs.HomePageURL))
+	return nil, lastErr
+}
+
+// probeConfigServices 并发探测config server的/services/config接口，按原顺序返回探测通过的config server
+func (b *autoFetchBalancer) probeConfigServices(css []string) []string {
+	var (
+		wg     sync.WaitGroup
+		passed = make([]bool, len(css))
+	)
+	for i, url := range css {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			passed[i] = b.probe(b.ctx, url) == nil
+		}(i, url)
 	}
+	wg.Wait()
 
-	return urls, nil
+	var urls []string
+	for i, url := range css {
+		if passed[i] {
+			urls = append(urls, url)
+		}
+	}
+	return urls
 }
 
 func (b *autoFetchBalancer) Select() (string, error) {
@@ -163,19 +234,54 @@ func (b *autoFetchBalancer) Select() (string, error) {
 	return b.b.Select()
 }
 
+// Report 连续失败的config server会被暂时摘除，刷新列表后仍然保留摘除状态
+func (b *autoFetchBalancer) Report(url string, err error) {
+	b.health.report(url, err)
+}
+
 func (b *autoFetchBalancer) Stop() {
-	close(b.stopCh)
+	b.stopOnce.Do(b.cancel)
+	b.health.stop()
+}
+
+// Shutdown 停止定时刷新ConfigServer列表，并等待刷新的goroutine退出
+func (b *autoFetchBalancer) Shutdown(ctx context.Context) error {
+	b.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return b.health.shutdown(ctx)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 type roundRobin struct {
 	ss []string
 	c  uint64
+
+	health     *healthChecker
+	ownsHealth bool // health由autoFetchBalancer共享时不负责停止
 }
 
-func NewRoundRobin(ss []string) Balancer {
+// NewRoundRobin 轮询选择config server，通过Report反馈失败的config server会被暂时摘除
+func NewRoundRobin(ss []string, opts ...HealthCheckOption) Balancer {
+	rr := newRoundRobin(ss, newHealthChecker(opts...))
+	rr.ownsHealth = true
+	return rr
+}
+
+func newRoundRobin(ss []string, health *healthChecker) *roundRobin {
 	return &roundRobin{
-		ss: ss,
-		c:  0,
+		ss:     ss,
+		c:      0,
+		health: health,
 	}
 }
 
@@ -185,10 +291,23 @@ func (rr *roundRobin) Select() (string, error) {
 	}
 
 	old := atomic.AddUint64(&rr.c, 1) - 1
-	idx := old % uint64(len(rr.ss))
-	return rr.ss[idx], nil
+	return rr.health.pick(rr.ss, old), nil
+}
+
+func (rr *roundRobin) Report(url string, err error) {
+	rr.health.report(url, err)
 }
 
 func (rr *roundRobin) Stop() {
+	if rr.ownsHealth {
+		rr.health.stop()
+	}
+}
 
+// Shutdown 等待主动探测的goroutine退出
+func (rr *roundRobin) Shutdown(ctx context.Context) error {
+	if !rr.ownsHealth {
+		return nil
+	}
+	return rr.health.shutdown(ctx)
 }
@@ -1,6 +1,9 @@
 package agollo
 
 import (
+	"context"
+	"errors"
+	"sort"
 	"sync"
 	"testing"
 	"time"
@@ -76,3 +79,95 @@ func TestRoundRobin(t *testing.T) {
 	}
 
 }
+
+func TestAutoFetchBalancerFailover(t *testing.T) {
+	var (
+		lock       sync.Mutex
+		metaDown   = map[string]bool{"http://meta1": true}
+		configDown = map[string]bool{"http://config2": true}
+	)
+	getConfigServers := func(url, appID string) (int, []ConfigServer, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if metaDown[url] || configDown[url] {
+			return 0, nil, errors.New("connection refused")
+		}
+		return 200, []ConfigServer{
+			{HomePageURL: "http://config1"},
+			{HomePageURL: "http://config2"},
+		}, nil
+	}
+
+	b, err := NewAutoFetchBalancer("http://meta1,http://meta2", "test", getConfigServers, time.Hour, NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+
+	// 跳过不可用的meta server，只使用探测通过的config server
+	for i := 0; i < 4; i++ {
+		actual, err := b.Select()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "http://config1", actual)
+	}
+
+	// meta server全部不可用时保留上一次的列表
+	lock.Lock()
+	metaDown["http://meta2"] = true
+	lock.Unlock()
+	assert.NotEqual(t, nil, b.(*autoFetchBalancer).updateConfigServices())
+
+	// config server全部探测失败时返回错误并保留上一次的列表
+	lock.Lock()
+	metaDown = map[string]bool{}
+	configDown["http://config1"] = true
+	lock.Unlock()
+	assert.Equal(t, ErrNoConfigServerAvailable, b.(*autoFetchBalancer).updateConfigServices())
+
+	actual, err := b.Select()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "http://config1", actual)
+
+	// 初始化时没有config server探测通过，以空列表启动，不会使用meta server的地址，之后刷新时恢复
+	empty, err := NewAutoFetchBalancer("http://meta1,http://meta2", "test", getConfigServers, time.Hour, NewLogger())
+	assert.Equal(t, nil, err)
+	defer empty.Stop()
+	_, err = empty.Select()
+	assert.Equal(t, ErrNoConfigServerAvailable, err)
+
+	lock.Lock()
+	delete(configDown, "http://config1")
+	lock.Unlock()
+	assert.Equal(t, nil, empty.(*autoFetchBalancer).updateConfigServices())
+	actual, err = empty.Select()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "http://config1", actual)
+
+	// 优先使用HealthCheckOptions中的Probe
+	var probed []string
+	b, err = NewAutoFetchBalancer("http://meta1,http://meta2", "test", getConfigServers, time.Hour, NewLogger(),
+		ActiveProbe(time.Hour, func(ctx context.Context, url string) error {
+			lock.Lock()
+			defer lock.Unlock()
+			probed = append(probed, url)
+			if url == "http://config2" {
+				return nil
+			}
+			return errors.New("probe failed")
+		}),
+	)
+	assert.Equal(t, nil, err)
+	defer b.Stop()
+	actual, err = b.Select()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "http://config2", actual)
+	sort.Strings(probed)
+	assert.Equal(t, []string{"http://config1", "http://config2"}, probed)
+
+	// meta server全部不可用时初始化失败
+	lock.Lock()
+	metaDown = map[string]bool{"http://meta1": true, "http://meta2": true}
+	lock.Unlock()
+	_, err = NewAutoFetchBalancer("http://meta1,http://meta2", "test", getConfigServers, time.Hour, NewLogger())
+	assert.NotEqual(t, nil, err)
+}
@@ -0,0 +1,170 @@
+package agollo
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+type BindOptions struct {
+	// 配置更新并成功解码后的回调，oldValue和newValue均为指向结构体的指针。
+	// 回调在应用release的goroutine(长轮询/兜底检查)中同步执行，阻塞会延迟之后所有namespace的更新，
+	// 耗时操作请自行异步处理
+	OnChange func(oldValue, newValue interface{})
+}
+
+type BindOption func(*BindOptions)
+
+func OnBindChange(fn func(oldValue, newValue interface{})) BindOption {
+	return func(o *BindOptions) {
+		o.OnChange = fn
+	}
+}
+
+// Binding 将namespace的配置与结构体绑定，配置发布后会重新解码并原子的替换
+type Binding struct {
+	agollo    *agollo
+	namespace string
+	typ       reflect.Type
+	value     atomic.Value
+	opts      BindOptions
+	lock      sync.Mutex // 串行化解码和替换，保证Bind初始化与配置更新之间不会互相覆盖
+	closeOnce sync.Once
+}
+
+// Load 返回当前最新的配置，类型为指向绑定结构体的指针，例如: Bind(ns, &conf)后Load().(*Config)
+// 每次更新都会解码到一个新的对象中，返回的对象不会再被修改，可以安全的并发读取
+func (b *Binding) Load() interface{} {
+	return b.value.Load()
+}
+
+// Close 解除绑定，之后的配置更新不再同步到该Binding
+func (b *Binding) Close() {
+	b.closeOnce.Do(func() {
+		b.agollo.removeBinding(b)
+	})
+}
+
+func (b *Binding) decode(conf Configurations) (interface{}, error) {
+	v := reflect.New(b.typ)
+	if err := conf.Unmarshal(v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+func (b *Binding) update(conf Configurations) error {
+	b.lock.Lock()
+	newValue, err := b.decode(conf)
+	if err != nil {
+		b.lock.Unlock()
+		return err
+	}
+
+	oldValue := b.value.Load()
+	b.value.Store(newValue)
+	b.lock.Unlock()
+
+	// Bind还未完成初始化时不触发回调，回调在锁外执行，回调中可以调用Rollback等再次更新配置的方法
+	if oldValue != nil && b.opts.OnChange != nil {
+		b.opts.OnChange(oldValue, newValue)
+	}
+	return nil
+}
+
+// init 解码当前的配置作为初始值，如果注册后已经收到了更新则保留更新后的值
+func (b *Binding) init(conf Configurations) (interface{}, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if value := b.value.Load(); value != nil {
+		return value, nil
+	}
+
+	value, err := b.decode(conf)
+	if err != nil {
+		return nil, err
+	}
+	b.value.Store(value)
+	return value, nil
+}
+
+// Bind 将namespace的配置解码到ptr中，并在Start之后持续同步配置的更新，
+// ptr仅保存绑定时的配置，之后的配置请通过Binding.Load获取。
+// 新发布的配置解码失败时保留旧值，并将错误发送到Start返回的errors channel中
+func (a *agollo) Bind(namespace string, ptr interface{}, opts ...BindOption) (*Binding, error) {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, ErrUnmarshalTarget
+	}
+
+	b := &Binding{
+		agollo:    a,
+		namespace: a.opts.remoteNamespace(namespace),
+		typ:       rv.Elem().Type(),
+	}
+	for _, opt := range opts {
+		opt(&b.opts)
+	}
+
+	// 非预加载以外的namespace,初始化基础meta信息,否则没有longpoll
+	ctx, cancel := a.withStopContext(context.Background())
+	defer cancel()
+	if err := a.initNamespace(ctx, b.namespace); err != nil {
+		a.log("Namespace", b.namespace, "Action", "Bind", "Error", err)
+	}
+
+	// 先注册再解码，避免两者之间发布的配置丢失
+	a.addBinding(b)
+
+	value, err := b.init(a.getNamespace(b.namespace))
+	if err != nil {
+		a.removeBinding(b)
+		return nil, err
+	}
+	rv.Elem().Set(reflect.ValueOf(value).Elem())
+
+	return b, nil
+}
+
+func (a *agollo) addBinding(b *Binding) {
+	a.bindingLock.Lock()
+	defer a.bindingLock.Unlock()
+
+	if a.bindings == nil {
+		a.bindings = map[string][]*Binding{}
+	}
+	watchNamespace := a.opts.fixWatchNamespace(b.namespace)
+	a.bindings[watchNamespace] = append(a.bindings[watchNamespace], b)
+}
+
+func (a *agollo) removeBinding(b *Binding) {
+	a.bindingLock.Lock()
+	defer a.bindingLock.Unlock()
+
+	watchNamespace := a.opts.fixWatchNamespace(b.namespace)
+	bindings := a.bindings[watchNamespace]
+	for i, binding := range bindings {
+		if binding == b {
+			a.bindings[watchNamespace] = append(bindings[:i:i], bindings[i+1:]...)
+			break
+		}
+	}
+	if len(a.bindings[watchNamespace]) == 0 {
+		delete(a.bindings, watchNamespace)
+	}
+}
+
+func (a *agollo) updateBindings(namespace string, conf Configurations) {
+	a.bindingLock.RLock()
+	bindings := append([]*Binding(nil), a.bindings[a.opts.fixWatchNamespace(namespace)]...)
+	a.bindingLock.RUnlock()
+
+	for _, b := range bindings {
+		if err := b.update(conf); err != nil {
+			a.log("Namespace", namespace, "Action", "UpdateBinding", "Error", err)
+			a.sendErrorsCh("", nil, namespace, err)
+		}
+	}
+}
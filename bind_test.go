@@ -0,0 +1,143 @@
+package agollo
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testBindConfig struct {
+	Timeout time.Duration `apollo:"timeout,default=1s"`
+	Hosts   []string      `apollo:"hosts"`
+}
+
+func TestAgolloBind(t *testing.T) {
+	backupfile, err := ioutil.TempFile("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(backupfile.Name())
+
+	var (
+		lock   sync.Mutex
+		config = &Config{
+			NamespaceName:  "application",
+			Configurations: Configurations{"timeout": "2s", "hosts": "a,b"},
+			ReleaseKey:     "1",
+		}
+		notificationID = 1
+	)
+	setConfig := func(conf Configurations, releaseKey string) {
+		lock.Lock()
+		defer lock.Unlock()
+		config = &Config{NamespaceName: "application", Configurations: conf, ReleaseKey: releaseKey}
+		notificationID++
+	}
+
+	client := &mockApolloClient{
+		notifications: func(configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, []Notification{{NamespaceName: "application", NotificationID: notificationID}}, nil
+		},
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, config, nil
+		},
+	}
+
+	ag, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		BackupFile(backupfile.Name()),
+	)
+	assert.Nil(t, err)
+	a := ag.(*agollo)
+
+	var (
+		conf     testBindConfig
+		changed  = make(chan [2]*testBindConfig, 1)
+		onChange = func(oldValue, newValue interface{}) {
+			changed <- [2]*testBindConfig{oldValue.(*testBindConfig), newValue.(*testBindConfig)}
+		}
+	)
+	b, err := a.Bind("application", &conf, OnBindChange(onChange))
+	assert.Nil(t, err)
+	assert.Equal(t, testBindConfig{Timeout: 2 * time.Second, Hosts: []string{"a", "b"}}, conf)
+	assert.Equal(t, &conf, b.Load())
+
+	// 新配置正常解码后替换
+	setConfig(Configurations{"hosts": "c"}, "2")
+	a.longPoll(context.Background())
+	assert.Equal(t, &testBindConfig{Timeout: time.Second, Hosts: []string{"c"}}, b.Load())
+	select {
+	case values := <-changed:
+		assert.Equal(t, &conf, values[0])
+		assert.Equal(t, b.Load(), values[1])
+	default:
+		t.Fatal("OnChange should be called")
+	}
+
+	// 解码失败保留旧值
+	setConfig(Configurations{"hosts": "c", "timeout": "foo"}, "3")
+	a.longPoll(context.Background())
+	assert.Equal(t, &testBindConfig{Timeout: time.Second, Hosts: []string{"c"}}, b.Load())
+	assert.Len(t, changed, 0)
+
+	// 解除绑定后不再更新
+	b.Close()
+	setConfig(Configurations{"hosts": "d"}, "4")
+	a.longPoll(context.Background())
+	assert.Equal(t, &testBindConfig{Timeout: time.Second, Hosts: []string{"c"}}, b.Load())
+}
+
+func TestBindingUpdateBeforeInit(t *testing.T) {
+	a := &agollo{opts: Options{}}
+	b := &Binding{agollo: a, namespace: "application", typ: reflect.TypeOf(testBindConfig{})}
+
+	// 注册后、初始解码前收到的更新不能被初始解码覆盖
+	a.addBinding(b)
+	a.updateBindings("application", Configurations{"hosts": "new"})
+
+	value, err := b.init(Configurations{"hosts": "old"})
+	assert.Nil(t, err)
+	assert.Equal(t, &testBindConfig{Timeout: time.Second, Hosts: []string{"new"}}, value)
+}
+
+func TestBindingOnChangeReentrant(t *testing.T) {
+	a := &agollo{opts: Options{}}
+	var (
+		b     *Binding
+		calls int
+	)
+	b = &Binding{agollo: a, namespace: "application", typ: reflect.TypeOf(testBindConfig{}),
+		opts: BindOptions{OnChange: func(oldValue, newValue interface{}) {
+			// 回调中再次更新同一个Binding，例如回滚配置
+			calls++
+			if calls == 1 {
+				b.update(Configurations{"hosts": "rollback"})
+			}
+		}},
+	}
+	_, err := b.init(Configurations{"hosts": "old"})
+	assert.Nil(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.update(Configurations{"hosts": "new"})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("OnChange deadlocked")
+	}
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, &testBindConfig{Timeout: time.Second, Hosts: []string{"rollback"}}, b.Load())
+}
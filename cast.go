@@ -0,0 +1,215 @@
+package agollo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ToInt64E casts an interface to an int64 type.
+func ToInt64E(i interface{}) (int64, error) {
+	i = indirectToStringerOrError(i)
+
+	switch s := i.(type) {
+	case int:
+		return int64(s), nil
+	case int64:
+		return s, nil
+	case int32:
+		return int64(s), nil
+	case int16:
+		return int64(s), nil
+	case int8:
+		return int64(s), nil
+	case uint:
+		if uint64(s) > math.MaxInt64 {
+			return 0, fmt.Errorf("unable to cast %#v of type %T to int64: overflow", i, i)
+		}
+		return int64(s), nil
+	case uint64:
+		if s > math.MaxInt64 {
+			return 0, fmt.Errorf("unable to cast %#v of type %T to int64: overflow", i, i)
+		}
+		return int64(s), nil
+	case uint32:
+		return int64(s), nil
+	case uint16:
+		return int64(s), nil
+	case uint8:
+		return int64(s), nil
+	case float64:
+		return int64(s), nil
+	case float32:
+		return int64(s), nil
+	case bool:
+		if s {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		v, err := strconv.ParseInt(strings.TrimSpace(s), 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unable to cast %#v of type %T to int64", i, i)
+		}
+		return v, nil
+	case nil:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("unable to cast %#v of type %T to int64", i, i)
+	}
+}
+
+// ToIntE casts an interface to an int type.
+func ToIntE(i interface{}) (int, error) {
+	v, err := ToInt64E(i)
+	if err != nil {
+		return 0, fmt.Errorf("unable to cast %#v of type %T to int", i, i)
+	}
+	return int(v), nil
+}
+
+// ToFloat64E casts an interface to a float64 type.
+func ToFloat64E(i interface{}) (float64, error) {
+	i = indirectToStringerOrError(i)
+
+	switch s := i.(type) {
+	case float64:
+		return s, nil
+	case float32:
+		return float64(s), nil
+	case int:
+		return float64(s), nil
+	case int64:
+		return float64(s), nil
+	case int32:
+		return float64(s), nil
+	case int16:
+		return float64(s), nil
+	case int8:
+		return float64(s), nil
+	case uint:
+		return float64(s), nil
+	case uint64:
+		return float64(s), nil
+	case uint32:
+		return float64(s), nil
+	case uint16:
+		return float64(s), nil
+	case uint8:
+		return float64(s), nil
+	case bool:
+		if s {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return 0, fmt.Errorf("unable to cast %#v of type %T to float64", i, i)
+		}
+		return v, nil
+	case nil:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("unable to cast %#v of type %T to float64", i, i)
+	}
+}
+
+// ToBoolE casts an interface to a bool type.
+func ToBoolE(i interface{}) (bool, error) {
+	i = indirectToStringerOrError(i)
+
+	switch b := i.(type) {
+	case bool:
+		return b, nil
+	case int:
+		return b != 0, nil
+	case int64:
+		return b != 0, nil
+	case float64:
+		return b != 0, nil
+	case string:
+		v, err := strconv.ParseBool(strings.TrimSpace(b))
+		if err != nil {
+			return false, fmt.Errorf("unable to cast %#v of type %T to bool", i, i)
+		}
+		return v, nil
+	case nil:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unable to cast %#v of type %T to bool", i, i)
+	}
+}
+
+// ToDurationE casts an interface to a time.Duration type.
+// 字符串中不带单位时按纳秒处理，例如："100" => 100ns, "3s" => 3s
+func ToDurationE(i interface{}) (time.Duration, error) {
+	i = indirectToStringerOrError(i)
+
+	switch s := i.(type) {
+	case time.Duration:
+		return s, nil
+	case int, int64, int32, int16, int8, uint, uint64, uint32, uint16, uint8:
+		v, err := ToInt64E(s)
+		return time.Duration(v), err
+	case float32, float64:
+		v, err := ToFloat64E(s)
+		return time.Duration(v), err
+	case string:
+		s = strings.TrimSpace(s)
+		if strings.ContainsAny(s, "nsuµmh") {
+			v, err := time.ParseDuration(s)
+			if err != nil {
+				return 0, fmt.Errorf("unable to cast %#v of type %T to time.Duration", i, i)
+			}
+			return v, nil
+		}
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unable to cast %#v of type %T to time.Duration", i, i)
+		}
+		return time.Duration(v), nil
+	case nil:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("unable to cast %#v of type %T to time.Duration", i, i)
+	}
+}
+
+// ToStringSliceE casts an interface to a []string type.
+// 字符串按逗号分隔，并去除每一项首尾的空白，例如："a, b,c" => []string{"a", "b", "c"}
+func ToStringSliceE(i interface{}) ([]string, error) {
+	switch v := i.(type) {
+	case []string:
+		return v, nil
+	case []interface{}:
+		var a []string
+		for _, u := range v {
+			s, err := ToStringE(u)
+			if err != nil {
+				return nil, fmt.Errorf("unable to cast %#v of type %T to []string", i, i)
+			}
+			a = append(a, s)
+		}
+		return a, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return []string{}, nil
+		}
+		var a []string
+		for _, s := range strings.Split(v, ",") {
+			a = append(a, strings.TrimSpace(s))
+		}
+		return a, nil
+	case nil:
+		return []string{}, nil
+	default:
+		s, err := ToStringE(v)
+		if err != nil {
+			return nil, fmt.Errorf("unable to cast %#v of type %T to []string", i, i)
+		}
+		return []string{s}, nil
+	}
+}
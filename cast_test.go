@@ -0,0 +1,45 @@
+package agollo
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToE(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		cast     func(interface{}) (interface{}, error)
+		expected interface{}
+		isErr    bool
+	}{
+		{"8", func(i interface{}) (interface{}, error) { return ToIntE(i) }, 8, false},
+		{" 0x10 ", func(i interface{}) (interface{}, error) { return ToIntE(i) }, 16, false},
+		{18.0, func(i interface{}) (interface{}, error) { return ToIntE(i) }, 18, false},
+		{"foo", func(i interface{}) (interface{}, error) { return ToIntE(i) }, 0, true},
+		{uint64(math.MaxInt64), func(i interface{}) (interface{}, error) { return ToInt64E(i) }, int64(math.MaxInt64), false},
+		{uint64(math.MaxInt64) + 1, func(i interface{}) (interface{}, error) { return ToInt64E(i) }, int64(0), true},
+		{"true", func(i interface{}) (interface{}, error) { return ToBoolE(i) }, true, false},
+		{"0", func(i interface{}) (interface{}, error) { return ToBoolE(i) }, false, false},
+		{"yes", func(i interface{}) (interface{}, error) { return ToBoolE(i) }, false, true},
+		{"1.5", func(i interface{}) (interface{}, error) { return ToFloat64E(i) }, 1.5, false},
+		{"3s", func(i interface{}) (interface{}, error) { return ToDurationE(i) }, 3 * time.Second, false},
+		{"100", func(i interface{}) (interface{}, error) { return ToDurationE(i) }, 100 * time.Nanosecond, false},
+		{"3x", func(i interface{}) (interface{}, error) { return ToDurationE(i) }, time.Duration(0), true},
+		{"a, b,c", func(i interface{}) (interface{}, error) { return ToStringSliceE(i) }, []string{"a", "b", "c"}, false},
+		{[]interface{}{"a", 1}, func(i interface{}) (interface{}, error) { return ToStringSliceE(i) }, []string{"a", "1"}, false},
+		{"", func(i interface{}) (interface{}, error) { return ToStringSliceE(i) }, []string{}, false},
+	}
+
+	for i, test := range tests {
+		actual, err := test.cast(test.input)
+		if test.isErr {
+			assert.Error(t, err, "test %d: %v", i, test.input)
+			continue
+		}
+		assert.NoError(t, err, "test %d: %v", i, test.input)
+		assert.Equal(t, test.expected, actual, "test %d: %v", i, test.input)
+	}
+}
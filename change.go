@@ -1,5 +1,10 @@
 package agollo
 
+import (
+	"fmt"
+	"strings"
+)
+
 type ChangeType string
 
 const (
@@ -9,13 +14,39 @@ const (
 )
 
 type Change struct {
-	Type  ChangeType
-	Key   string
-	Value interface{}
+	Type     ChangeType
+	Key      string
+	Value    interface{} // add、update时为新值，delete时为旧值
+	OldValue interface{} // add时为nil
+	NewValue interface{} // delete时为nil
+}
+
+// String 返回可读的变更信息，例如：
+// timeout: 100 -> 200
+// timeout: (added) 200
+// timeout: 100 (deleted)
+func (c Change) String() string {
+	switch c.Type {
+	case ChangeTypeAdd:
+		return fmt.Sprintf("%s: (added) %v", c.Key, c.NewValue)
+	case ChangeTypeDelete:
+		return fmt.Sprintf("%s: %v (deleted)", c.Key, c.OldValue)
+	default:
+		return fmt.Sprintf("%s: %v -> %v", c.Key, c.OldValue, c.NewValue)
+	}
 }
 
 type Changes []Change
 
+// String 按行输出每个变更
+func (cs Changes) String() string {
+	lines := make([]string, 0, len(cs))
+	for _, c := range cs {
+		lines = append(lines, c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
 // Len is part of sort.Interface.
 func (cs Changes) Len() int {
 	return len(cs)
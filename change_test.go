@@ -0,0 +1,17 @@
+package agollo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangesString(t *testing.T) {
+	old := Configurations{"timeout": 100, "name": "foo", "retry": 3}
+	new := Configurations{"timeout": 200, "name": "foo", "host": "localhost"}
+
+	changes := old.Different(new)
+	assert.Equal(t, "host: (added) localhost\nretry: 3 (deleted)\ntimeout: 100 -> 200", changes.String())
+	assert.Equal(t, "timeout: 100 -> 200", changes[2].String())
+	assert.Equal(t, "", Changes(nil).String())
+}
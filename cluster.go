@@ -0,0 +1,135 @@
+package agollo
+
+import (
+	"context"
+	"net/http"
+)
+
+// getConfigsFromNonCache 依次从Options.clusters中的集群获取namespace的配置，直到namespace存在，
+// 返回的Config.Cluster为配置实际所在的集群。成功后记住请求的集群，之后直接请求该集群，
+// 该集群返回404(例如namespace被删除)时再依次尝试其他集群
+func (a *agollo) getConfigsFromNonCache(ctx context.Context, configServerURL, namespace, releaseKey string) (status int, config *Config, err error) {
+	for _, cluster := range a.requestClustersOf(namespace) {
+		status, config, err = a.client.GetConfigsFromNonCacheWithContext(
+			ctx,
+			configServerURL,
+			a.opts.AppID,
+			cluster,
+			namespace,
+			ReleaseKey(releaseKey),
+		)
+		if err != nil || status != http.StatusNotFound {
+			if status == http.StatusOK || status == http.StatusNotModified {
+				a.requestClusters.Store(namespace, cluster)
+			}
+			// 服务端回退到数据中心或者default集群时返回实际所在的集群
+			if status == http.StatusOK && config.Cluster == "" {
+				config.Cluster = cluster
+			}
+			return
+		}
+	}
+	return
+}
+
+// requestClustersOf 返回获取namespace配置时依次请求的集群，已经确定请求集群的namespace优先请求该集群
+func (a *agollo) requestClustersOf(namespace string) []string {
+	clusters := a.opts.clusters()
+	requestCluster, found := a.requestClusters.Load(namespace)
+	if !found {
+		return clusters
+	}
+
+	ordered := []string{requestCluster.(string)}
+	for _, cluster := range clusters {
+		if cluster != ordered[0] {
+			ordered = append(ordered, cluster)
+		}
+	}
+	return ordered
+}
+
+// getRequestCluster 返回请求namespace的配置及长轮询时使用的集群，未确定时为Options.Cluster。
+// 与getCluster不同，服务端自行回退到数据中心或者default集群时仍然是请求时的集群
+func (a *agollo) getRequestCluster(namespace string) string {
+	if cluster, found := a.requestClusters.Load(namespace); found {
+		return cluster.(string)
+	}
+	return a.opts.Cluster
+}
+
+// pollNotifications 按照namespace请求时使用的集群分组长轮询，ClusterFallback回退到其他集群的namespace
+// 同样能收到推送。多个集群时并发请求，任意一个集群成功返回后取消其他集群的请求并合并已返回的结果，
+// 失败的集群不会中断其他集群的长轮询，所有集群都失败时才返回错误
+func (a *agollo) pollNotifications(ctx context.Context) ([]Notification, error) {
+	groups := map[string][]Notification{}
+	for _, notification := range a.getLocalNotifications() {
+		cluster := a.getRequestCluster(notification.NamespaceName)
+		groups[cluster] = append(groups[cluster], notification)
+	}
+	if len(groups) <= 1 {
+		for cluster, req := range groups {
+			return a.getRemoteNotifications(ctx, cluster, req)
+		}
+		return a.getRemoteNotifications(ctx, a.opts.Cluster, nil)
+	}
+
+	type result struct {
+		req           []Notification
+		notifications []Notification
+		err           error
+	}
+	pollCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan result, len(groups))
+	for cluster, req := range groups {
+		go func(cluster string, req []Notification) {
+			notifications, err := a.getRemoteNotifications(pollCtx, cluster, req)
+			results <- result{req, notifications, err}
+		}(cluster, req)
+	}
+
+	var (
+		notifications []Notification
+		succeeded     bool
+		failed        []result // 成功之前失败的集群，成功之后取消导致的失败忽略
+	)
+	for i := 0; i < len(groups); i++ {
+		r := <-results
+		if r.err == nil {
+			notifications = append(notifications, r.notifications...)
+			if !succeeded {
+				succeeded = true
+				cancel()
+			}
+		} else if !succeeded {
+			failed = append(failed, r)
+		}
+	}
+
+	if !succeeded {
+		return nil, failed[0].err
+	}
+	for _, r := range failed {
+		a.sendErrorsCh("", r.req, "", r.err)
+	}
+	return notifications, nil
+}
+
+// getCluster 返回namespace的配置实际所在的集群，未加载时为Options.Cluster
+func (a *agollo) getCluster(namespace string) string {
+	if cluster, found := a.clusters.Load(namespace); found {
+		return cluster.(string)
+	}
+	return a.opts.Cluster
+}
+
+func (a *agollo) setCluster(namespace, cluster string) {
+	if cluster == "" {
+		cluster = a.opts.Cluster
+	}
+	a.clusters.Store(namespace, cluster)
+	a.updateStatus(namespace, func(s *NamespaceStatus) {
+		s.Cluster = cluster
+	})
+}
@@ -0,0 +1,198 @@
+package agollo
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApolloClientDataCenter(t *testing.T) {
+	var queries []string
+	client := NewApolloClient(
+		WithDataCenter("SHAJQ"),
+		WithDoer(&mockDoer{
+			do: func(req *http.Request) (*http.Response, error) {
+				queries = append(queries, req.URL.Query().Get("dataCenter"))
+				if strings.HasPrefix(req.URL.Path, "/notifications/") {
+					return newMockResponse(304, ""), nil
+				}
+				return newMockResponse(200, `{}`), nil
+			},
+		}),
+	)
+
+	_, _, err := client.Notifications("localhost:8080", "test", "default",
+		[]Notification{{NamespaceName: "application", NotificationID: -1}})
+	assert.Nil(t, err)
+	_, _, err = client.GetConfigsFromNonCache("localhost:8080", "test", "default", "application")
+	assert.Nil(t, err)
+	_, _ = client.GetConfigsFromCache("localhost:8080", "test", "default", "application")
+	assert.Equal(t, []string{"SHAJQ", "SHAJQ", "SHAJQ"}, queries)
+}
+
+// mockClusterServer 模拟apollo服务端的集群回退：依次查找请求的集群、数据中心的同名集群及default集群，
+// 都不存在时返回404，客户端的ClusterFallback只对服务端不会查找的集群有意义
+type mockClusterServer struct {
+	lock          sync.Mutex
+	dataCenter    string
+	releases      map[string]map[string]*Config // key: cluster value: key: namespace
+	configsCalls  []string                      // 请求configs时的cluster/namespace
+	notifyCalls   []string                      // 请求notifications时的cluster
+	notifications map[string]int                // key: cluster/namespace value: notificationID
+	down          map[string]bool               // 长轮询立即失败的集群
+}
+
+func (s *mockClusterServer) lookup(cluster, namespace string) *Config {
+	for _, c := range []string{cluster, s.dataCenter, defaultCluster} {
+		if config, found := s.releases[c][namespace]; found {
+			return config
+		}
+	}
+	return nil
+}
+
+func (s *mockClusterServer) publish(cluster, namespace string, conf Configurations, releaseKey string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.releases[cluster] == nil {
+		s.releases[cluster] = map[string]*Config{}
+	}
+	s.releases[cluster][namespace] = &Config{Cluster: cluster, NamespaceName: namespace, Configurations: conf, ReleaseKey: releaseKey}
+	s.notifications[cluster+"/"+namespace]++
+}
+
+func (s *mockClusterServer) client() *mockApolloClient {
+	return &mockApolloClient{
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			s.configsCalls = append(s.configsCalls, cluster+"/"+namespace)
+			config := s.lookup(cluster, namespace)
+			if config == nil {
+				return 404, nil, nil
+			}
+			c := *config
+			return 200, &c, nil
+		},
+		notifications: func(configServerURL, appID, cluster string, notifications []Notification) (int, []Notification, error) {
+			s.lock.Lock()
+			s.notifyCalls = append(s.notifyCalls, cluster)
+			if s.down[cluster] {
+				s.lock.Unlock()
+				return 0, nil, errConnectionRefused
+			}
+			var changed []Notification
+			for _, n := range notifications {
+				config := s.lookup(cluster, n.NamespaceName)
+				if config == nil {
+					continue
+				}
+				id := s.notifications[config.Cluster+"/"+n.NamespaceName]
+				if id > n.NotificationID {
+					changed = append(changed, Notification{NamespaceName: n.NamespaceName, NotificationID: id})
+				}
+			}
+			s.lock.Unlock()
+
+			if len(changed) == 0 {
+				// 模拟服务端hold住没有变化的请求
+				time.Sleep(50 * time.Millisecond)
+				return 304, nil, nil
+			}
+			// 模拟网络耗时，晚于立即失败的集群返回
+			time.Sleep(10 * time.Millisecond)
+			return 200, changed, nil
+		},
+	}
+}
+
+func TestAgolloClusterFallback(t *testing.T) {
+	server := &mockClusterServer{
+		dataCenter:    "SHAJQ",
+		releases:      map[string]map[string]*Config{},
+		notifications: map[string]int{},
+	}
+	server.publish("default", "application", Configurations{"foo": "default"}, "1")
+	server.publish("SHAJQ", "idc", Configurations{"foo": "idc"}, "1")
+	server.publish("shared", "shared", Configurations{"foo": "shared"}, "1")
+
+	store := NewMemoryBackupStore()
+	ag, err := New("localhost:8080", "test",
+		WithApolloClient(server.client()),
+		Cluster("custom"),
+		DataCenter("SHAJQ"),
+		ClusterFallback("shared"),
+		PreloadNamespaces("application", "idc", "shared"),
+		WithBackupStore(store),
+	)
+	assert.Nil(t, err)
+	a := ag.(*agollo)
+
+	// 服务端能回退到的集群直接返回，只有shared需要客户端回退
+	assert.Equal(t, []string{"custom/application", "custom/idc", "custom/shared", "shared/shared"}, server.configsCalls)
+	assert.Equal(t, "default", a.Get("foo"))
+	assert.Equal(t, "default", a.Status()["application"].Cluster)
+	assert.Equal(t, "idc", a.Get("foo", WithNamespace("idc")))
+	assert.Equal(t, "SHAJQ", a.Status()["idc"].Cluster)
+	assert.Equal(t, "shared", a.Get("foo", WithNamespace("shared")))
+	assert.Equal(t, "shared", a.Status()["shared"].Cluster)
+
+	backup, err := store.Load("shared")
+	assert.Nil(t, err)
+	assert.Equal(t, "shared", backup.Cluster)
+
+	// 之后直接请求namespace所在的集群
+	server.configsCalls = nil
+	_, _, err = a.reloadNamespace(context.Background(), "shared")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"shared/shared"}, server.configsCalls)
+
+	// 客户端回退的namespace使用所在的集群长轮询，同样能收到推送
+	server.publish("shared", "shared", Configurations{"foo": "shared2"}, "2")
+	server.notifyCalls = nil
+	assert.Nil(t, a.longPoll(context.Background()))
+	assert.Equal(t, "shared2", a.Get("foo", WithNamespace("shared")))
+	sort.Strings(server.notifyCalls)
+	assert.Equal(t, []string{"custom", "shared"}, server.notifyCalls)
+
+	// 服务端回退的namespace使用Cluster长轮询即可
+	server.publish("default", "application", Configurations{"foo": "default2"}, "2")
+	assert.Nil(t, a.longPoll(context.Background()))
+	assert.Equal(t, "default2", a.Get("foo"))
+
+	// 一个集群的长轮询立即失败不影响其他集群的推送
+	server.lock.Lock()
+	server.down = map[string]bool{"shared": true}
+	server.lock.Unlock()
+	server.publish("default", "application", Configurations{"foo": "default3"}, "3")
+	assert.Nil(t, a.longPoll(context.Background()))
+	assert.Equal(t, "default3", a.Get("foo"))
+
+	// 所有集群都失败时返回错误
+	server.lock.Lock()
+	server.down = map[string]bool{"shared": true, "custom": true}
+	server.lock.Unlock()
+	assert.Equal(t, errConnectionRefused, a.longPoll(context.Background()))
+	server.lock.Lock()
+	server.down = nil
+	server.lock.Unlock()
+
+	// 未开启ClusterFallback时只从Cluster加载
+	server.configsCalls = nil
+	ag, err = New("localhost:8080", "test",
+		WithApolloClient(server.client()),
+		Cluster("custom"),
+		DataCenter("SHAJQ"),
+		PreloadNamespaces("shared"),
+		WithBackupStore(NewMemoryBackupStore()),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"custom/shared"}, server.configsCalls)
+	assert.Equal(t, "", ag.Get("foo", WithNamespace("shared")))
+}
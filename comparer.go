@@ -0,0 +1,55 @@
+package agollo
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Comparer 判断配置值是否相等，用于Configurations.DifferentWith计算配置变更
+type Comparer func(oldValue, newValue interface{}) bool
+
+// DeepEqualComparer 使用reflect.DeepEqual比较，map、slice等嵌套结构递归比较，1与"1"不相等
+func DeepEqualComparer(oldValue, newValue interface{}) bool {
+	return reflect.DeepEqual(oldValue, newValue)
+}
+
+// NormalizedComparer 将标量统一转换成字符串后比较，1、1.0与"1"，true与"true"相等，
+// map、slice等嵌套结构递归比较
+func NormalizedComparer(oldValue, newValue interface{}) bool {
+	return reflect.DeepEqual(normalizeValue(oldValue), normalizeValue(newValue))
+}
+
+func normalizeValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return normalizeValue(rv.Elem().Interface())
+	case reflect.Map:
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = normalizeValue(iter.Value().Interface())
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(rv.Bytes())
+		}
+		s := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			s[i] = normalizeValue(rv.Index(i).Interface())
+		}
+		return s
+	case reflect.Struct:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
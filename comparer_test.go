@@ -0,0 +1,63 @@
+package agollo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigurationsDifferentWith(t *testing.T) {
+	old := Configurations{
+		"hosts":   []interface{}{"a", "b"},
+		"db":      map[string]interface{}{"port": 3306},
+		"timeout": 1,
+		"debug":   "true",
+	}
+	new := Configurations{
+		"hosts":   []interface{}{"a", "b"},
+		"db":      map[string]interface{}{"port": "3306"},
+		"timeout": "1",
+		"debug":   true,
+	}
+
+	// slice、map不会panic，类型不同视为变更
+	changes := old.Different(new)
+	assert.Equal(t, []string{"db", "debug", "timeout"}, changeKeys(changes))
+
+	changes = old.DifferentWith(new, NormalizedComparer)
+	assert.Len(t, changes, 0)
+
+	new["hosts"] = []interface{}{"a", "c"}
+	changes = old.DifferentWith(new, NormalizedComparer)
+	assert.Equal(t, []string{"hosts"}, changeKeys(changes))
+}
+
+func TestNormalizedComparer(t *testing.T) {
+	tests := []struct {
+		oldValue interface{}
+		newValue interface{}
+		equal    bool
+	}{
+		{1, "1", true},
+		{1.0, int64(1), true},
+		{1.5, "1.5", true},
+		{nil, nil, true},
+		{nil, "", false},
+		{[]string{"a"}, []interface{}{"a"}, true},
+		{[]byte("a"), "a", true},
+		{map[interface{}]interface{}{"a": 1}, map[string]interface{}{"a": "1"}, true},
+		{map[string]interface{}{"a": 1}, map[string]interface{}{"a": 2}, false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.equal, NormalizedComparer(test.oldValue, test.newValue), "%v %v", test.oldValue, test.newValue)
+	}
+}
+
+func changeKeys(changes Changes) []string {
+	var keys []string
+	for _, change := range changes {
+		keys = append(keys, change.Key)
+	}
+	return keys
+}
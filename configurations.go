@@ -4,23 +4,36 @@ import "sort"
 
 type Configurations map[string]interface{}
 
+// Different 使用DeepEqualComparer比较新旧配置，返回按key排序的变更
 func (old Configurations) Different(new Configurations) Changes {
+	return old.DifferentWith(new, DeepEqualComparer)
+}
+
+// DifferentWith 使用指定的Comparer比较新旧配置，comparer为nil时使用DeepEqualComparer
+func (old Configurations) DifferentWith(new Configurations, comparer Comparer) Changes {
+	if comparer == nil {
+		comparer = DeepEqualComparer
+	}
+
 	var changes []Change
 	for k, newValue := range new {
 		oldValue, found := old[k]
 		if found {
-			if oldValue != newValue {
+			if !comparer(oldValue, newValue) {
 				changes = append(changes, Change{
-					Type:  ChangeTypeUpdate,
-					Key:   k,
-					Value: newValue,
+					Type:     ChangeTypeUpdate,
+					Key:      k,
+					Value:    newValue,
+					OldValue: oldValue,
+					NewValue: newValue,
 				})
 			}
 		} else {
 			changes = append(changes, Change{
-				Type:  ChangeTypeAdd,
-				Key:   k,
-				Value: newValue,
+				Type:     ChangeTypeAdd,
+				Key:      k,
+				Value:    newValue,
+				NewValue: newValue,
 			})
 		}
 	}
@@ -29,9 +42,10 @@ func (old Configurations) Different(new Configurations) Changes {
 		_, found := new[k]
 		if !found {
 			changes = append(changes, Change{
-				Type:  ChangeTypeDelete,
-				Key:   k,
-				Value: oldValue,
+				Type:     ChangeTypeDelete,
+				Key:      k,
+				Value:    oldValue,
+				OldValue: oldValue,
 			})
 		}
 	}
@@ -1,6 +1,10 @@
 package agollo
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func TestConfigurationsDifferent(t *testing.T) {
 	tests := []struct {
@@ -21,26 +25,28 @@ func TestConfigurationsDifferent(t *testing.T) {
 				"height": 1.82,
 			},
 			[]Change{
-				Change{ChangeTypeUpdate, "age", 19},
-				Change{ChangeTypeDelete, "balance", 101.2},
-				Change{ChangeTypeAdd, "height", 1.82},
+				Change{Type: ChangeTypeUpdate, Key: "age", Value: 19, OldValue: 18, NewValue: 19},
+				Change{Type: ChangeTypeDelete, Key: "balance", Value: 101.2, OldValue: 101.2},
+				Change{Type: ChangeTypeAdd, Key: "height", Value: 1.82, NewValue: 1.82},
+			},
+		},
+		{
+			Configurations{
+				"hosts": []interface{}{"a", "b"},
+				"tags":  []interface{}{"x"},
+			},
+
+			Configurations{
+				"hosts": []interface{}{"a", "c"},
+				"tags":  []interface{}{"x"},
+			},
+			[]Change{
+				Change{Type: ChangeTypeUpdate, Key: "hosts", Value: []interface{}{"a", "c"}, OldValue: []interface{}{"a", "b"}, NewValue: []interface{}{"a", "c"}},
 			},
 		},
 	}
 
 	for _, test := range tests {
-		changes := test.old.Different(test.new)
-
-		if len(changes) != len(test.changes) {
-			t.Errorf("should be equal (expected=%v, actual=%v)", test.changes, len(changes))
-		}
-		for i, actual := range changes {
-			expected := test.changes[i]
-			if actual.Type != expected.Type &&
-				actual.Key != expected.Key &&
-				actual.Value != expected.Value {
-				t.Errorf("should be equal (expected=%v, actual=%v)", expected, actual)
-			}
-		}
+		assert.Equal(t, Changes(test.changes), test.old.Different(test.new))
 	}
 }
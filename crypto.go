@@ -0,0 +1,92 @@
+package agollo
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+var (
+	// encryptedBackupMagic 加密备份的前缀，用于区分加密及未加密的备份
+	encryptedBackupMagic = []byte("AGOLLO-ENC1:")
+
+	ErrBackupKeyRequired  = errors.New("backup is encrypted but no key provided")
+	ErrBackupNotEncrypted = errors.New("backup is not encrypted")
+)
+
+// KeyProvider 提供加密备份的AES密钥，密钥长度为16、24或32字节，分别对应AES-128、AES-192、AES-256
+type KeyProvider interface {
+	Key() ([]byte, error)
+}
+
+type KeyProviderFunc func() ([]byte, error)
+
+func (f KeyProviderFunc) Key() ([]byte, error) {
+	return f()
+}
+
+// StaticKey 使用固定的密钥
+func StaticKey(key []byte) KeyProvider {
+	return KeyProviderFunc(func() ([]byte, error) {
+		return key, nil
+	})
+}
+
+func newGCM(kp KeyProvider) (cipher.AEAD, error) {
+	key, err := kp.Key()
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptBackup 使用AES-GCM加密，格式为：magic + nonce + ciphertext
+func encryptBackup(kp KeyProvider, data []byte) ([]byte, error) {
+	gcm, err := newGCM(kp)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(nil), encryptedBackupMagic...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, data, encryptedBackupMagic), nil
+}
+
+// decryptBackup 解密备份，设置了密钥时只有allowPlaintext为true才接受未加密的备份，
+// 防止加密的备份被替换为明文的配置
+func decryptBackup(kp KeyProvider, data []byte, allowPlaintext bool) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedBackupMagic) {
+		if kp != nil && !allowPlaintext {
+			return nil, ErrBackupNotEncrypted
+		}
+		return data, nil
+	}
+	if kp == nil {
+		return nil, ErrBackupKeyRequired
+	}
+
+	gcm, err := newGCM(kp)
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[len(encryptedBackupMagic):]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted backup is too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, encryptedBackupMagic)
+}
@@ -0,0 +1,68 @@
+package agollo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptedBackupStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "agollo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		key    = StaticKey([]byte("0123456789abcdef0123456789abcdef"))
+		path   = filepath.Join(dir, ".agollo")
+		store  = NewFileBackupStore(path, EncryptBackup(key))
+		backup = NewBackup("application", "test", "default", "1", Configurations{"db.password": "secret"})
+	)
+	assert.Nil(t, store.Save("application", backup))
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.True(t, bytes.HasPrefix(data, encryptedBackupMagic))
+	assert.False(t, bytes.Contains(data, []byte("secret")))
+
+	loaded, err := store.Load("application")
+	assert.Nil(t, err)
+	assert.Equal(t, backup.Configurations, loaded.Configurations)
+
+	// 没有密钥或者密钥错误时无法读取
+	_, err = NewFileBackupStore(path).Load("application")
+	assert.Equal(t, ErrBackupKeyRequired, err)
+	_, err = NewFileBackupStore(path, EncryptBackup(StaticKey([]byte("fedcba9876543210")))).Load("application")
+	assert.NotNil(t, err)
+
+	// 开启加密前的备份默认拒绝读取，迁移时显式允许
+	dirStore := NewDirBackupStore(filepath.Join(dir, "dir"))
+	assert.Nil(t, dirStore.Save("application", backup))
+	_, err = NewDirBackupStore(filepath.Join(dir, "dir"), EncryptBackup(key)).Load("application")
+	assert.Equal(t, ErrBackupNotEncrypted, err)
+	loaded, err = NewDirBackupStore(filepath.Join(dir, "dir"), EncryptBackup(key), AllowPlaintextBackup()).Load("application")
+	assert.Nil(t, err)
+	assert.Equal(t, backup.Configurations, loaded.Configurations)
+
+	// 无法读取的备份文件移动到.corrupt后重新写入
+	plainPath := filepath.Join(dir, ".plain")
+	assert.Nil(t, NewFileBackupStore(plainPath).Save("application", backup))
+	assert.Nil(t, NewFileBackupStore(plainPath, EncryptBackup(key)).Save("other", backup))
+	_, err = NewFileBackupStore(plainPath, EncryptBackup(key)).Load("application")
+	assert.Equal(t, ErrBackupNotFound, err)
+	loaded, err = NewFileBackupStore(plainPath, EncryptBackup(key)).Load("other")
+	assert.Nil(t, err)
+	assert.Equal(t, backup.Configurations, loaded.Configurations)
+	loaded, err = NewFileBackupStore(plainPath + ".corrupt").Load("application")
+	assert.Nil(t, err)
+	assert.Equal(t, backup.Configurations, loaded.Configurations)
+}
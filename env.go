@@ -0,0 +1,122 @@
+package agollo
+
+import (
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/magiconair/properties"
+)
+
+// apollo的环境，与java客户端的com.ctrip.framework.apollo.core.enums.Env一致
+const (
+	EnvLocal = "LOCAL"
+	EnvDev   = "DEV"
+	EnvFAT   = "FAT"
+	EnvUAT   = "UAT"
+	EnvLPT   = "LPT"
+	EnvPro   = "PRO"
+	EnvTools = "TOOLS"
+)
+
+var (
+	// serverPropertiesPath 与java客户端相同，保存env、idc等服务器级别的配置
+	serverPropertiesPath = defaultServerPropertiesPath()
+	// apolloEnvPropertiesPath 保存各环境meta server地址的配置文件，格式为：dev.meta=http://localhost:8080
+	apolloEnvPropertiesPath = "apollo-env.properties"
+)
+
+func defaultServerPropertiesPath() string {
+	if runtime.GOOS == "windows" {
+		return `C:\opt\settings\server.properties`
+	}
+	return "/opt/settings/server.properties"
+}
+
+// loadProperties 读取properties文件，文件不存在或者解析失败时返回空的配置
+func loadProperties(path string) *properties.Properties {
+	if _, err := os.Stat(path); err != nil {
+		return properties.NewProperties()
+	}
+	p, err := properties.LoadFile(path, properties.UTF8)
+	if err != nil {
+		return properties.NewProperties()
+	}
+	return p
+}
+
+func getServerProperty(key string) string {
+	v, _ := loadProperties(serverPropertiesPath).Get(key)
+	return strings.TrimSpace(v)
+}
+
+// normalizeEnv 统一环境名称为大写，兼容java客户端的别名：FWS -> FAT，PROD -> PRO
+func normalizeEnv(env string) string {
+	env = strings.ToUpper(strings.TrimSpace(env))
+	switch env {
+	case "FWS":
+		return EnvFAT
+	case "PROD":
+		return EnvPro
+	}
+	return env
+}
+
+/*
+参考了java客户端实现，取下列表中非空的一项:
+0. 客户端显式传入的Env
+1. 环境变量ENV
+2. server.properties中的env
+*/
+func getEnv(env string) string {
+	return normalizeEnv(nonEmptyString("",
+		env,
+		os.Getenv("ENV"),
+		getServerProperty("env"),
+	))
+}
+
+/*
+参考了java客户端实现，取下列表中非空的一项:
+1. 环境变量IDC
+2. server.properties中的idc
+*/
+func getIDC() string {
+	return nonEmptyString("",
+		strings.TrimSpace(os.Getenv("IDC")),
+		getServerProperty("idc"),
+	)
+}
+
+// getEnvMetaServer 获取env对应的meta server地址，优先级顺序为：
+// 环境变量{ENV}_META > apollo-env.properties中的{env}.meta
+func getEnvMetaServer(env string) string {
+	if env == "" {
+		return ""
+	}
+	if url := os.Getenv(env + "_META"); url != "" {
+		return url
+	}
+	url, _ := loadProperties(apolloEnvPropertiesPath).Get(strings.ToLower(env) + ".meta")
+	return strings.TrimSpace(url)
+}
+
+/*
+参考了java客户端实现，取下列表中非空的一项:
+0. 客户端显式传入的configServerURL
+1. 环境变量APOLLO_META
+2. server.properties中的apollo.meta
+3. 环境变量{ENV}_META，例如：DEV_META
+4. apollo-env.properties中的{env}.meta，例如：dev.meta
+
+都为空时返回空字符串，使用默认的meta server地址
+https://github.com/ctripcorp/apollo/blob/7545bd3cd7d4b996d7cda50f53cd4aa8b045a2bb/apollo-core/src/main/java/com/ctrip/framework/apollo/core/MetaDomainConsts.java#L27
+*/
+func getMetaServerURL(configServerURL, env string) string {
+	return nonEmptyString("",
+		configServerURL,
+		os.Getenv("APOLLO_META"),
+		getServerProperty("apollo.meta"),
+		getEnvMetaServer(getEnv(env)),
+	)
+}
@@ -0,0 +1,82 @@
+package agollo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setenv 设置环境变量，返回恢复原值的函数
+func setenv(key, value string) func() {
+	old, found := os.LookupEnv(key)
+	os.Setenv(key, value)
+	return func() {
+		if found {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestEnvResolution(t *testing.T) {
+	dir, err := ioutil.TempDir("", "agollo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldServerPropertiesPath, oldApolloEnvPropertiesPath := serverPropertiesPath, apolloEnvPropertiesPath
+	defer func() {
+		serverPropertiesPath, apolloEnvPropertiesPath = oldServerPropertiesPath, oldApolloEnvPropertiesPath
+	}()
+	serverPropertiesPath = filepath.Join(dir, "server.properties")
+	apolloEnvPropertiesPath = filepath.Join(dir, "apollo-env.properties")
+
+	for _, key := range []string{"ENV", "IDC", "APOLLO_META", "APOLLO_CONFIGSERVICE", "FAT_META", "PRO_META"} {
+		defer setenv(key, "")()
+	}
+
+	// 配置文件不存在
+	assert.Equal(t, "", getEnv(""))
+	assert.Equal(t, "", getIDC())
+	assert.Equal(t, "", getMetaServerURL("", ""))
+
+	assert.Nil(t, ioutil.WriteFile(serverPropertiesPath, []byte("env=fws\nidc=SHAJQ\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(apolloEnvPropertiesPath, []byte("fat.meta=http://fat-meta:8080\npro.meta=http://pro-meta:8080\n"), 0644))
+
+	assert.Equal(t, EnvFAT, getEnv(""))
+	assert.Equal(t, "SHAJQ", getIDC())
+	assert.Equal(t, "http://fat-meta:8080", getMetaServerURL("", ""))
+
+	// 显式传入的Env优先
+	assert.Equal(t, EnvPro, getEnv("prod"))
+	assert.Equal(t, "http://pro-meta:8080", getMetaServerURL("", "prod"))
+
+	// 环境变量优先于配置文件
+	defer setenv("ENV", "PRO")()
+	defer setenv("IDC", "SHAOY")()
+	defer setenv("PRO_META", "http://pro-meta-env:8080")()
+	assert.Equal(t, EnvPro, getEnv(""))
+	assert.Equal(t, "SHAOY", getIDC())
+	assert.Equal(t, "http://pro-meta-env:8080", getMetaServerURL("", ""))
+
+	defer setenv("APOLLO_META", "http://apollo-meta:8080")()
+	assert.Equal(t, "http://apollo-meta:8080", getMetaServerURL("", ""))
+	assert.Equal(t, "localhost:8080", getMetaServerURL("localhost:8080", ""))
+
+	// 未指定集群时使用所在数据中心的同名集群
+	opts, err := newOptions("localhost:8080", "test")
+	assert.Nil(t, err)
+	assert.Equal(t, EnvPro, opts.Env)
+	assert.Equal(t, "SHAOY", opts.DataCenter)
+	assert.Equal(t, "SHAOY", opts.Cluster)
+
+	opts, err = newOptions("localhost:8080", "test", Cluster("test"), Env("dev"))
+	assert.Nil(t, err)
+	assert.Equal(t, EnvDev, opts.Env)
+	assert.Equal(t, "test", opts.Cluster)
+}
@@ -0,0 +1,96 @@
+package agollo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic 先写入同目录下的临时文件并fsync，再rename覆盖目标文件，
+// 进程在写入过程中崩溃也不会留下不完整的文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	if err = mkdirAll(dir); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+name+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	syncDir(dir)
+	return nil
+}
+
+// moveCorruptFile 将无法读取的文件重命名为path.corrupt，保留原文件便于排查及手动恢复，
+// 之后可以重新写入，不会因为一个损坏的文件导致之后的写入一直失败
+func moveCorruptFile(path string) error {
+	return os.Rename(path, path+".corrupt")
+}
+
+// syncDir 持久化目录项，保证rename在断电后依然生效，部分平台不支持时忽略错误
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// fileLock 跨进程的文件锁，锁定path对应的.lock文件，
+// 不直接锁定备份文件是因为rename之后备份文件已经不是同一个文件
+type fileLock struct {
+	f *os.File
+}
+
+func newFileLock(path string) (*fileLock, error) {
+	if err := mkdirAll(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileLock{f: f}, nil
+}
+
+func (l *fileLock) Lock() error {
+	return lockFile(l.f, true)
+}
+
+func (l *fileLock) RLock() error {
+	return lockFile(l.f, false)
+}
+
+func (l *fileLock) Unlock() error {
+	defer l.f.Close()
+	return unlockFile(l.f)
+}
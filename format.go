@@ -0,0 +1,232 @@
+package agollo
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ConfigTypeProperties = "properties"
+	ConfigTypeXML        = "xml"
+	ConfigTypeJSON       = "json"
+	ConfigTypeYML        = "yml"
+	ConfigTypeYAML       = "yaml"
+	ConfigTypeTXT        = "txt"
+
+	// 非properties格式的namespace，apollo将整个文件内容放在content中返回
+	ContentKey = "content"
+
+	xmlAttrPrefix = "@"
+	xmlTextKey    = "#text"
+)
+
+var supportedConfigTypes = []string{
+	ConfigTypeProperties,
+	ConfigTypeXML,
+	ConfigTypeJSON,
+	ConfigTypeYML,
+	ConfigTypeYAML,
+	ConfigTypeTXT,
+}
+
+// getConfigTypeByExt 根据namespace的后缀名判断配置格式，不支持的后缀名返回空，
+// 例如: datasource.json => json, TEST.Namespace1 => ""
+func getConfigTypeByExt(namespace string) string {
+	ext := strings.TrimPrefix(path.Ext(namespace), ".")
+	if stringInSlice(ext, supportedConfigTypes) {
+		return ext
+	}
+	return ""
+}
+
+// trimConfigTypeExt 去掉namespace中表示配置格式的后缀名，例如: datasource.json => datasource
+func trimConfigTypeExt(namespace string) string {
+	if ext := getConfigTypeByExt(namespace); ext != "" {
+		return strings.TrimSuffix(namespace, "."+ext)
+	}
+	return namespace
+}
+
+// parseConfigurations 解析json/yaml/xml格式namespace的content，
+// 并以"."拼接key的方式展开到Configurations中，原始内容仍保留在content中，
+// 例如: {"db":{"host":"localhost"}} => {"content": `{"db":{"host":"localhost"}}`, "db.host": "localhost"}
+// 数组不展开，以[]interface{}的形式保存。
+// xml的根节点作为key的第一段，属性以"@"开头，同时存在属性和文本的节点文本保存在"#text"中。
+// properties和txt格式不做处理
+func parseConfigurations(configType string, conf Configurations) (Configurations, error) {
+	v, structured, err := parseDocument(configType, conf)
+	if !structured {
+		return conf, nil
+	}
+	if err != nil {
+		return conf, err
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return conf, nil
+	}
+
+	parsed := Configurations{}
+	flatten("", m, parsed)
+	for k, val := range conf {
+		parsed[k] = val
+	}
+	return parsed, nil
+}
+
+// parseDocument 解析json/yaml/xml格式namespace的content，structured为false表示不是结构化的格式
+func parseDocument(configType string, conf Configurations) (v interface{}, structured bool, err error) {
+	switch configType {
+	case ConfigTypeJSON:
+		v, err = parseContent(conf, func(data []byte, v *interface{}) error {
+			return json.Unmarshal(data, v)
+		})
+	case ConfigTypeYML, ConfigTypeYAML:
+		v, err = parseContent(conf, func(data []byte, v *interface{}) error {
+			if err := yaml.Unmarshal(data, v); err != nil {
+				return err
+			}
+			*v = normalizeYAML(*v)
+			return nil
+		})
+	case ConfigTypeXML:
+		v, err = parseContent(conf, parseXML)
+	default:
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("parse %s content: %v", configType, err)
+	}
+	return v, true, nil
+}
+
+func parseContent(conf Configurations, unmarshal func([]byte, *interface{}) error) (interface{}, error) {
+	content, err := ToStringE(conf[ContentKey])
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, nil
+	}
+
+	var v interface{}
+	if err := unmarshal([]byte(content), &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func flatten(prefix string, m map[string]interface{}, out Configurations) {
+	for k, v := range m {
+		key := prefix + k
+		if sub, ok := v.(map[string]interface{}); ok && len(sub) > 0 {
+			flatten(key+".", sub, out)
+			continue
+		}
+		out[key] = v
+	}
+}
+
+// normalizeYAML yaml.v2解析出的map为map[interface{}]interface{}，统一转换为map[string]interface{}
+func normalizeYAML(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(vv))
+		for k, val := range vv {
+			m[fmt.Sprint(k)] = normalizeYAML(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range vv {
+			vv[i] = normalizeYAML(val)
+		}
+		return vv
+	default:
+		return v
+	}
+}
+
+type xmlNode struct {
+	attrs    []xml.Attr
+	children []*xmlNode
+	name     string
+	text     bytes.Buffer
+}
+
+func (n *xmlNode) value() interface{} {
+	text := strings.TrimSpace(n.text.String())
+	if len(n.attrs) == 0 && len(n.children) == 0 {
+		return text
+	}
+
+	m := map[string]interface{}{}
+	for _, attr := range n.attrs {
+		m[xmlAttrPrefix+attr.Name.Local] = attr.Value
+	}
+	for _, child := range n.children {
+		val := child.value()
+		if exists, found := m[child.name]; found {
+			// 同名的子节点合并为数组
+			if list, ok := exists.([]interface{}); ok {
+				m[child.name] = append(list, val)
+			} else {
+				m[child.name] = []interface{}{exists, val}
+			}
+			continue
+		}
+		m[child.name] = val
+	}
+	if text != "" {
+		m[xmlTextKey] = text
+	}
+	return m
+}
+
+func parseXML(data []byte, v *interface{}) error {
+	var (
+		dec   = xml.NewDecoder(bytes.NewReader(data))
+		root  *xmlNode
+		stack []*xmlNode
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			} else if root == nil {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil
+	}
+	*v = map[string]interface{}{root.name: root.value()}
+	return nil
+}
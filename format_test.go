@@ -0,0 +1,104 @@
+package agollo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceConfigType(t *testing.T) {
+	opts := Options{
+		ConfigTypes: map[string]string{
+			"routes":          ConfigTypeYAML,
+			"TEST.Namespace1": ConfigTypeJSON,
+		},
+	}
+
+	tests := []struct {
+		namespace  string
+		configType string
+		remote     string
+		qualified  string
+	}{
+		{"application", ConfigTypeProperties, "application", "application.properties"},
+		{"test.properties", ConfigTypeProperties, "test", "test.properties"},
+		{"datasource.json", ConfigTypeJSON, "datasource.json", "datasource.json"},
+		{"routes.yml", ConfigTypeYML, "routes.yml", "routes.yml"},
+		{"routes", ConfigTypeYAML, "routes.yaml", "routes.yaml"},
+		{"beans.xml", ConfigTypeXML, "beans.xml", "beans.xml"},
+		{"readme.txt", ConfigTypeTXT, "readme.txt", "readme.txt"},
+		{"TEST.Namespace1", ConfigTypeJSON, "TEST.Namespace1.json", "TEST.Namespace1.json"},
+		{"TEST.Namespace2", ConfigTypeProperties, "TEST.Namespace2", "TEST.Namespace2.properties"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.configType, opts.getConfigType(test.namespace), test.namespace)
+		assert.Equal(t, test.remote, opts.remoteNamespace(test.namespace), test.namespace)
+		assert.Equal(t, test.qualified, opts.fixWatchNamespace(test.namespace), test.namespace)
+	}
+}
+
+func TestParseConfigurations(t *testing.T) {
+	tests := []struct {
+		configType string
+		content    string
+		expected   Configurations
+		isErr      bool
+	}{
+		{
+			ConfigTypeJSON,
+			`{"db":{"host":"localhost","port":3306},"hosts":["a","b"]}`,
+			Configurations{
+				"db.host": "localhost",
+				"db.port": float64(3306),
+				"hosts":   []interface{}{"a", "b"},
+			},
+			false,
+		},
+		{
+			ConfigTypeYAML,
+			"db:\n  host: localhost\n  port: 3306\nhosts:\n  - a\n  - b\n",
+			Configurations{
+				"db.host": "localhost",
+				"db.port": 3306,
+				"hosts":   []interface{}{"a", "b"},
+			},
+			false,
+		},
+		{
+			ConfigTypeXML,
+			`<config><db port="3306"><host>localhost</host></db><host>a</host><host>b</host></config>`,
+			Configurations{
+				"config.db.@port": "3306",
+				"config.db.host":  "localhost",
+				"config.host":     []interface{}{"a", "b"},
+			},
+			false,
+		},
+		{
+			ConfigTypeTXT,
+			"plain text",
+			Configurations{},
+			false,
+		},
+		{
+			ConfigTypeJSON,
+			`{"db":`,
+			Configurations{},
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		conf := Configurations{ContentKey: test.content}
+		actual, err := parseConfigurations(test.configType, conf)
+		if test.isErr {
+			assert.Error(t, err, test.content)
+		} else {
+			assert.NoError(t, err, test.content)
+		}
+
+		test.expected[ContentKey] = test.content
+		assert.Equal(t, test.expected, actual, test.content)
+	}
+}
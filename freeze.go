@@ -0,0 +1,92 @@
+package agollo
+
+import "errors"
+
+var ErrNamespaceFrozen = errors.New("namespace is frozen")
+
+// Freeze 冻结namespace，冻结期间继续跟踪apollo的新release，但不更新缓存也不通知监听者，
+// 调用Unfreeze后生效最新的release
+func (a *agollo) Freeze(namespace string) {
+	namespace = a.opts.remoteNamespace(namespace)
+
+	a.freezeLock.Lock()
+	defer a.freezeLock.Unlock()
+	if a.frozen == nil {
+		a.frozen = map[string]*Config{}
+	}
+	if _, found := a.frozen[namespace]; found {
+		return
+	}
+	a.frozen[namespace] = nil
+
+	a.updateStatus(namespace, func(s *NamespaceStatus) {
+		s.Frozen = true
+	})
+}
+
+// Unfreeze 解除冻结，冻结期间收到了新的release时立即生效并通知监听者
+func (a *agollo) Unfreeze(namespace string) error {
+	namespace = a.opts.remoteNamespace(namespace)
+
+	a.releaseLock.Lock()
+	a.freezeLock.Lock()
+	config, found := a.frozen[namespace]
+	if !found {
+		a.freezeLock.Unlock()
+		a.releaseLock.Unlock()
+		return nil
+	}
+	delete(a.frozen, namespace)
+	a.updateStatus(namespace, func(s *NamespaceStatus) {
+		s.Frozen = false
+		s.PendingReleaseKey = ""
+	})
+
+	if config == nil || a.isPinned(namespace, config.ReleaseKey) {
+		a.freezeLock.Unlock()
+		a.releaseLock.Unlock()
+		return nil
+	}
+
+	oldValue := a.getNamespace(namespace)
+	newValue, err := a.applyRelease(namespace, config)
+	a.freezeLock.Unlock()
+	a.releaseLock.Unlock()
+
+	// 释放锁后再通知，回调中可以再次调用Rollback、Unfreeze，未消费的订阅也不会阻塞新的release
+	a.sendWatchCh(namespace, oldValue, newValue)
+	return err
+}
+
+// holdRelease namespace已冻结时记录最新的release并返回true
+func (a *agollo) holdRelease(namespace string, config *Config) bool {
+	a.freezeLock.Lock()
+	defer a.freezeLock.Unlock()
+
+	if _, found := a.frozen[namespace]; !found {
+		return false
+	}
+	a.frozen[namespace] = config
+
+	// 记录最新的release_key，之后apollo返回304，直到有新的release
+	a.releaseKeyMap.Store(namespace, config.ReleaseKey)
+	a.updateStatus(namespace, func(s *NamespaceStatus) {
+		s.PendingReleaseKey = config.ReleaseKey
+	})
+	return true
+}
+
+// isHeld namespace已回滚或者已冻结，apollo的新release暂不生效
+func (a *agollo) isHeld(namespace string) bool {
+	if _, pinned := a.pinnedReleases.Load(namespace); pinned {
+		return true
+	}
+	return a.isFrozen(namespace)
+}
+
+func (a *agollo) isFrozen(namespace string) bool {
+	a.freezeLock.Lock()
+	defer a.freezeLock.Unlock()
+	_, frozen := a.frozen[namespace]
+	return frozen
+}
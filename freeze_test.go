@@ -0,0 +1,158 @@
+package agollo
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgolloFreeze(t *testing.T) {
+	var (
+		lock       sync.Mutex
+		releaseKey = 1
+		requests   []string
+	)
+	publish := func() {
+		lock.Lock()
+		defer lock.Unlock()
+		releaseKey++
+	}
+
+	client := &mockApolloClient{
+		notifications: func(configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, []Notification{{NamespaceName: "application", NotificationID: releaseKey}}, nil
+		},
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			var options NotificationsOptions
+			for _, opt := range opts {
+				opt(&options)
+			}
+			requests = append(requests, options.ReleaseKey)
+			if options.ReleaseKey == strconv.Itoa(releaseKey) {
+				return 304, nil, nil
+			}
+			return 200, &Config{
+				NamespaceName:  "application",
+				Configurations: Configurations{"foo": strconv.Itoa(releaseKey)},
+				ReleaseKey:     strconv.Itoa(releaseKey),
+			}, nil
+		},
+	}
+
+	ag, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		PreloadNamespaces("application"),
+		WithBackupStore(NewMemoryBackupStore()),
+	)
+	assert.Nil(t, err)
+	a := ag.(*agollo)
+	s := a.Subscribe("application")
+
+	a.Freeze("application")
+	assert.True(t, a.Status()["application"].Frozen)
+
+	publish()
+	a.longPoll(context.Background())
+	publish()
+	a.longPoll(context.Background())
+	a.heartBeat(context.Background())
+
+	// 冻结期间继续跟踪release，但是不生效
+	assert.Equal(t, "1", a.Get("foo"))
+	assert.Equal(t, "3", a.Status()["application"].PendingReleaseKey)
+	assert.Equal(t, []string{"", "1", "2", "3"}, requests)
+	assert.Len(t, s.C(), 0)
+	notificationID, _ := a.notificationMap.Load("application")
+	assert.Equal(t, 3, notificationID)
+
+	// 解除冻结后生效最新的release
+	assert.Nil(t, a.Unfreeze("application"))
+	assert.Equal(t, "3", a.Get("foo"))
+	status := a.Status()["application"]
+	assert.False(t, status.Frozen)
+	assert.Equal(t, "", status.PendingReleaseKey)
+	assert.Equal(t, "3", status.ReleaseKey)
+
+	resp := receiveResponse(t, s.C())
+	assert.Equal(t, "1", resp.OldValue["foo"])
+	assert.Equal(t, "3", resp.NewValue["foo"])
+
+	// 冻结期间没有新的release时解除冻结不会通知
+	a.Freeze("application")
+	assert.Nil(t, a.Unfreeze("application"))
+	assert.Len(t, s.C(), 0)
+}
+
+func TestAgolloUnfreezeCallbackRollback(t *testing.T) {
+	var (
+		lock       sync.Mutex
+		releaseKey = 1
+	)
+	client := &mockApolloClient{
+		notifications: func(configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, []Notification{{NamespaceName: "application", NotificationID: releaseKey}}, nil
+		},
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, &Config{
+				NamespaceName:  "application",
+				Configurations: Configurations{"hosts": strconv.Itoa(releaseKey)},
+				ReleaseKey:     strconv.Itoa(releaseKey),
+			}, nil
+		},
+	}
+
+	ag, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		PreloadNamespaces("application"),
+		WithBackupStore(NewMemoryBackupStore()),
+		BackupHistorySize(5),
+	)
+	assert.Nil(t, err)
+	a := ag.(*agollo)
+
+	// 回调中回滚配置不能死锁
+	var (
+		conf  testBindConfig
+		calls int
+	)
+	_, err = a.Bind("application", &conf, OnBindChange(func(oldValue, newValue interface{}) {
+		calls++
+		if calls == 1 {
+			assert.Nil(t, a.Rollback("application", "1"))
+		}
+	}))
+	assert.Nil(t, err)
+
+	a.Freeze("application")
+	lock.Lock()
+	releaseKey = 2
+	lock.Unlock()
+	a.longPoll(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Unfreeze("application")
+	}()
+	select {
+	case err = <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Unfreeze deadlocked")
+	}
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, "1", a.Get("hosts"))
+	assert.Equal(t, NamespaceSourceRollback, a.Status()["application"].Source)
+}
@@ -0,0 +1,241 @@
+package agollo
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	defaultBaseEjectionTime = 1 * time.Second
+	defaultMaxEjectionTime  = 60 * time.Second
+)
+
+// BalancerReporter Balancer实现该接口时，agollo会将每次访问config server的结果反馈给Balancer，
+// err为nil表示访问成功
+type BalancerReporter interface {
+	Report(url string, err error)
+}
+
+// ProbeFunc 主动探测config server是否可用，返回nil表示可用
+type ProbeFunc func(ctx context.Context, url string) error
+
+type HealthCheckOptions struct {
+	BaseEjectionTime time.Duration // 第一次失败后摘除的时间，之后每次连续失败翻倍，默认：1s
+	MaxEjectionTime  time.Duration // 摘除时间的上限，0表示不限制，默认：60s
+	ProbeInterval    time.Duration // 主动探测被摘除的config server的间隔，默认：0不探测
+	Probe            ProbeFunc     // 主动探测的方式，未设置时不探测
+}
+
+type HealthCheckOption func(*HealthCheckOptions)
+
+// EjectionTime 设置连续失败时摘除config server的时间，第n次连续失败摘除base*2^(n-1)，最长max，
+// max为0时不限制摘除时间的上限
+func EjectionTime(base, max time.Duration) HealthCheckOption {
+	return func(o *HealthCheckOptions) {
+		o.BaseEjectionTime = base
+		o.MaxEjectionTime = max
+	}
+}
+
+// ActiveProbe 每隔interval使用probe探测被摘除的config server，探测成功后立即恢复，
+// 在agollo中probe可以传nil，默认通过/services/config接口探测
+func ActiveProbe(interval time.Duration, probe ProbeFunc) HealthCheckOption {
+	return func(o *HealthCheckOptions) {
+		o.ProbeInterval = interval
+		if probe != nil {
+			o.Probe = probe
+		}
+	}
+}
+
+func withProbe(probe ProbeFunc) HealthCheckOption {
+	return func(o *HealthCheckOptions) {
+		o.Probe = probe
+	}
+}
+
+// configServerProbe 通过/services/config接口探测config server，返回200时可用
+func configServerProbe(getConfigServers getConfigServersWithContextFunc, appID string) ProbeFunc {
+	return func(ctx context.Context, url string) error {
+		status, _, err := getConfigServers(ctx, url, appID)
+		if err == nil && status == http.StatusOK {
+			return nil
+		}
+		return statusError(status, err)
+	}
+}
+
+type serverHealth struct {
+	failures     int       // 连续失败次数
+	ejectedUntil time.Time // 摘除的截止时间
+}
+
+// healthChecker 根据访问结果摘除连续失败的config server，摘除时间到期后重新参与选择，
+// 再次失败时摘除时间翻倍，成功后恢复
+type healthChecker struct {
+	opts HealthCheckOptions
+
+	lock    sync.Mutex
+	servers map[string]*serverHealth // key: config server url
+
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+func newHealthChecker(opts ...HealthCheckOption) *healthChecker {
+	options := HealthCheckOptions{
+		BaseEjectionTime: defaultBaseEjectionTime,
+		MaxEjectionTime:  defaultMaxEjectionTime,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	h := &healthChecker{
+		opts:    options,
+		servers: map[string]*serverHealth{},
+	}
+
+	var ctx context.Context
+	ctx, h.cancel = context.WithCancel(context.Background())
+	if options.ProbeInterval > 0 && options.Probe != nil {
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			h.runProbe(ctx)
+		}()
+	}
+	return h
+}
+
+func (h *healthChecker) report(url string, err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if err == nil {
+		delete(h.servers, url)
+		return
+	}
+
+	s, found := h.servers[url]
+	if !found {
+		s = &serverHealth{}
+		h.servers[url] = s
+	}
+	s.failures++
+	s.ejectedUntil = time.Now().Add(h.ejectionTime(s.failures))
+}
+
+// ejectionTime 第failures次连续失败的摘除时间，MaxEjectionTime为0时不限制上限
+func (h *healthChecker) ejectionTime(failures int) time.Duration {
+	max := h.opts.MaxEjectionTime
+	d := h.opts.BaseEjectionTime
+	for i := 1; i < failures && d > 0; i++ {
+		if (max > 0 && d >= max) || d > math.MaxInt64/2 {
+			break
+		}
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
+}
+
+// pick 在未被摘除的config server中轮询选择，
+// 全部被摘除时选择最早到期的，避免无可用的config server
+func (h *healthChecker) pick(ss []string, n uint64) string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var (
+		now       = time.Now()
+		available = make([]string, 0, len(ss))
+		fallback  string
+		earliest  time.Time
+	)
+	for _, url := range ss {
+		s, found := h.servers[url]
+		if !found || !now.Before(s.ejectedUntil) {
+			available = append(available, url)
+			continue
+		}
+		if fallback == "" || s.ejectedUntil.Before(earliest) {
+			fallback, earliest = url, s.ejectedUntil
+		}
+	}
+	if len(available) == 0 {
+		return fallback
+	}
+	return available[n%uint64(len(available))]
+}
+
+// ejected 返回当前被摘除的config server
+func (h *healthChecker) ejected() []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	now := time.Now()
+	var urls []string
+	for url, s := range h.servers {
+		if now.Before(s.ejectedUntil) {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// retain 只保留ss中config server的状态，用于config server列表更新后清理已下线的实例
+func (h *healthChecker) retain(ss []string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for url := range h.servers {
+		if !stringInSlice(url, ss) {
+			delete(h.servers, url)
+		}
+	}
+}
+
+func (h *healthChecker) runProbe(ctx context.Context) {
+	ticker := time.NewTicker(h.opts.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, url := range h.ejected() {
+				if err := h.opts.Probe(ctx, url); err == nil {
+					h.report(url, nil)
+				}
+			}
+		}
+	}
+}
+
+func (h *healthChecker) stop() {
+	h.stopOnce.Do(h.cancel)
+}
+
+func (h *healthChecker) shutdown(ctx context.Context) error {
+	h.stop()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,130 @@
+package agollo
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errConnectionRefused = errors.New("connection refused")
+
+func selectN(t *testing.T, b Balancer, n int) map[string]int {
+	selected := map[string]int{}
+	for i := 0; i < n; i++ {
+		url, err := b.Select()
+		assert.Nil(t, err)
+		selected[url]++
+	}
+	return selected
+}
+
+func TestRoundRobinEjection(t *testing.T) {
+	b := NewRoundRobin([]string{"a", "b", "c"}, EjectionTime(50*time.Millisecond, 100*time.Millisecond))
+	defer b.Stop()
+	r := b.(BalancerReporter)
+
+	r.Report("a", errConnectionRefused)
+	selected := selectN(t, b, 6)
+	assert.Equal(t, 0, selected["a"])
+	assert.Equal(t, 3, selected["b"])
+	assert.Equal(t, 3, selected["c"])
+
+	// 摘除时间到期后重新参与选择
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, 2, selectN(t, b, 6)["a"])
+
+	// 连续失败时摘除时间翻倍
+	r.Report("a", errConnectionRefused)
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, 0, selectN(t, b, 6)["a"])
+
+	// 成功后立即恢复
+	r.Report("a", nil)
+	assert.Equal(t, 2, selectN(t, b, 6)["a"])
+
+	// 全部被摘除时选择最早到期的
+	r.Report("b", errConnectionRefused)
+	r.Report("c", errConnectionRefused)
+	r.Report("a", errConnectionRefused)
+	assert.Equal(t, map[string]int{"b": 3}, selectN(t, b, 3))
+}
+
+func TestHealthCheckerEjectionTime(t *testing.T) {
+	h := newHealthChecker(EjectionTime(time.Second, 5*time.Second))
+	defer h.stop()
+	assert.Equal(t, time.Second, h.ejectionTime(1))
+	assert.Equal(t, 2*time.Second, h.ejectionTime(2))
+	assert.Equal(t, 4*time.Second, h.ejectionTime(3))
+	assert.Equal(t, 5*time.Second, h.ejectionTime(4))
+	assert.Equal(t, 5*time.Second, h.ejectionTime(10))
+
+	// max为0时不限制上限
+	h = newHealthChecker(EjectionTime(time.Second, 0))
+	defer h.stop()
+	assert.Equal(t, time.Second, h.ejectionTime(1))
+	assert.Equal(t, 8*time.Second, h.ejectionTime(4))
+	assert.Equal(t, 512*time.Second, h.ejectionTime(10))
+	assert.True(t, h.ejectionTime(100) > 0)
+}
+
+func TestRoundRobinActiveProbe(t *testing.T) {
+	var healthy int32
+	b := NewRoundRobin([]string{"a", "b"},
+		EjectionTime(time.Hour, time.Hour),
+		ActiveProbe(10*time.Millisecond, func(ctx context.Context, url string) error {
+			if atomic.LoadInt32(&healthy) == 1 {
+				return nil
+			}
+			return errConnectionRefused
+		}),
+	)
+	b.(BalancerReporter).Report("a", errConnectionRefused)
+	assert.Equal(t, 0, selectN(t, b, 4)["a"])
+
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 2, selectN(t, b, 4)["a"])
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, b.(balancerShutdowner).Shutdown(ctx))
+}
+
+func TestAgolloReportsConfigServer(t *testing.T) {
+	client := &mockApolloClient{
+		notifications: func(configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error) {
+			if configServerURL == "a" {
+				return 0, nil, errConnectionRefused
+			}
+			return 304, nil, nil
+		},
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			if configServerURL == "a" {
+				return 503, nil, nil
+			}
+			return 200, &Config{NamespaceName: "application", Configurations: Configurations{"foo": "bar"}, ReleaseKey: "1"}, nil
+		},
+	}
+
+	b := NewRoundRobin([]string{"a", "b"})
+	a, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		WithBalancer(b),
+		PreloadNamespaces("application"),
+		WithBackupStore(NewMemoryBackupStore()),
+	)
+	assert.Nil(t, err)
+
+	// 初始化时访问失败的config server被摘除，之后的请求只访问健康的config server
+	assert.Equal(t, map[string]int{"b": 4}, selectN(t, b, 4))
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, a.(*agollo).longPoll(context.Background()))
+	}
+	_, conf, err := a.(*agollo).reloadNamespace(context.Background(), "application")
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", conf["foo"])
+}
@@ -0,0 +1,223 @@
+package agollo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+var ErrBackupHistoryUnsupported = errors.New("backup store does not support history")
+
+// BackupHistoryStore 支持保存历史版本的BackupStore，用于History及Rollback
+type BackupHistoryStore interface {
+	BackupStore
+	// SaveHistory 保存一个历史版本，相同release key的版本只保留最新的一个，最多保留size个版本
+	SaveHistory(namespace string, backup *Backup, size int) error
+	// History 返回namespace的历史版本，按获取时间从新到旧排序
+	History(namespace string) ([]*Backup, error)
+}
+
+// appendHistory 将backup插入到历史版本的最前面，并去掉相同release key的旧版本
+func appendHistory(history []*Backup, backup *Backup, size int) []*Backup {
+	h := []*Backup{backup}
+	for _, b := range history {
+		if len(h) >= size {
+			break
+		}
+		if b.ReleaseKey != backup.ReleaseKey {
+			h = append(h, b)
+		}
+	}
+	return h
+}
+
+func (s *fileBackupStore) SaveHistory(namespace string, backup *Backup, size int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.withFileLock(true, func() error {
+		file, err := s.loadFileForUpdate()
+		if err != nil {
+			return err
+		}
+		if file.History == nil {
+			file.History = map[string][]*Backup{}
+		}
+		file.History[namespace] = appendHistory(file.History[namespace], backup, size)
+		return s.saveFile(file)
+	})
+}
+
+func (s *fileBackupStore) History(namespace string) (history []*Backup, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	err = s.withFileLock(false, func() error {
+		file, err := s.loadFile()
+		if err == ErrBackupNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		history = file.History[namespace]
+		return nil
+	})
+	return history, err
+}
+
+// historyPath 历史版本保存在dir/.history目录下，避免与namespace的备份文件重名
+func (s *dirBackupStore) historyPath(namespace string) string {
+	return filepath.Join(s.dir, ".history", filepath.Base(s.path(namespace)))
+}
+
+// SaveHistory 读取-修改-写入在与备份文件相同的跨进程文件锁内完成，避免多个进程同时保存时丢失版本
+func (s *dirBackupStore) SaveHistory(namespace string, backup *Backup, size int) error {
+	return withFileLock(s.historyPath(namespace), true, func() error {
+		history, err := s.History(namespace)
+		if err != nil {
+			// 无法读取的历史版本移动到.corrupt后重新开始
+			if err = moveCorruptFile(s.historyPath(namespace)); err != nil {
+				return err
+			}
+			history = nil
+		}
+
+		data, err := json.Marshal(appendHistory(history, backup, size))
+		if err != nil {
+			return err
+		}
+		return s.opts.writeFile(s.historyPath(namespace), data)
+	})
+}
+
+func (s *dirBackupStore) History(namespace string) ([]*Backup, error) {
+	data, err := s.opts.readFile(s.historyPath(namespace))
+	if err == ErrBackupNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var history []*Backup
+	if err = json.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (s *memoryBackupStore) SaveHistory(namespace string, backup *Backup, size int) error {
+	s.historyLock.Lock()
+	defer s.historyLock.Unlock()
+
+	if s.history == nil {
+		s.history = map[string][]*Backup{}
+	}
+	b := *backup
+	b.Configurations = copyConfigurations(backup.Configurations)
+	s.history[namespace] = appendHistory(s.history[namespace], &b, size)
+	return nil
+}
+
+func (s *memoryBackupStore) History(namespace string) ([]*Backup, error) {
+	s.historyLock.Lock()
+	defer s.historyLock.Unlock()
+
+	var history []*Backup
+	for _, backup := range s.history[namespace] {
+		b := *backup
+		b.Configurations = copyConfigurations(backup.Configurations)
+		history = append(history, &b)
+	}
+	return history, nil
+}
+
+// saveHistory 开启BackupHistorySize并且BackupStore支持时保存历史版本
+func (a *agollo) saveHistory(namespace, releaseKey string, config Configurations) error {
+	store, ok := a.opts.BackupStore.(BackupHistoryStore)
+	if !ok || a.opts.BackupHistorySize <= 0 {
+		return nil
+	}
+	return store.SaveHistory(namespace,
+		NewBackup(namespace, a.opts.AppID, a.getCluster(namespace), releaseKey, config),
+		a.opts.BackupHistorySize)
+}
+
+// History 返回namespace在本地保存的历史版本，按获取时间从新到旧排序，
+// 需要设置BackupHistorySize并且BackupStore实现了BackupHistoryStore
+func (a *agollo) History(namespace string) ([]*Backup, error) {
+	store, ok := a.opts.BackupStore.(BackupHistoryStore)
+	if !ok {
+		return nil, ErrBackupHistoryUnsupported
+	}
+	return store.History(a.opts.remoteNamespace(namespace))
+}
+
+// Rollback 将namespace回滚到本地保存的releaseKey对应的版本，并通知监听者，
+// 回滚后忽略当前的release，直到apollo发布了一个不同的release。
+// 回滚的配置同样需要通过Validator校验，namespace已冻结时返回ErrNamespaceFrozen
+func (a *agollo) Rollback(namespace, releaseKey string) error {
+	namespace = a.opts.remoteNamespace(namespace)
+
+	history, err := a.History(namespace)
+	if err != nil {
+		return err
+	}
+
+	var backup *Backup
+	for _, b := range history {
+		if b.ReleaseKey == releaseKey {
+			backup = b
+			break
+		}
+	}
+	if backup == nil {
+		return fmt.Errorf("release %s of namespace %s not found in history", releaseKey, namespace)
+	}
+	if err = backup.Verify(); err != nil {
+		return err
+	}
+
+	// 与应用apollo release的逻辑串行，避免回滚的配置与新的release互相覆盖
+	a.releaseLock.Lock()
+	if a.isFrozen(namespace) {
+		a.releaseLock.Unlock()
+		return ErrNamespaceFrozen
+	}
+
+	oldValue := a.getNamespace(namespace)
+	newValue := a.parseConfigurations(namespace, backup.Configurations)
+	if err = a.validate(namespace, backup.ReleaseKey, oldValue, newValue); err != nil {
+		a.releaseLock.Unlock()
+		return err
+	}
+
+	// 记录回滚时的release，apollo返回该release时不再覆盖回滚的配置
+	if currentReleaseKey, found := a.releaseKeyMap.Load(namespace); found {
+		a.pinnedReleases.Store(namespace, currentReleaseKey)
+	}
+	a.cache.Store(namespace, newValue)
+	a.setCluster(namespace, backup.Cluster)
+	a.setLoaded(namespace, NamespaceSourceRollback, backup.ReleaseKey, backup.FetchedAt, nil)
+	a.releaseLock.Unlock()
+
+	a.sendWatchCh(namespace, oldValue, newValue)
+	return nil
+}
+
+// isPinned 判断apollo返回的release是否是回滚时的release，是则忽略该release，
+// 收到不同的release时解除回滚
+func (a *agollo) isPinned(namespace, releaseKey string) bool {
+	pinned, found := a.pinnedReleases.Load(namespace)
+	if !found {
+		return false
+	}
+	if pinned.(string) == releaseKey {
+		return true
+	}
+
+	a.pinnedReleases.Delete(namespace)
+	return false
+}
@@ -0,0 +1,177 @@
+package agollo
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupHistoryStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "agollo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stores := map[string]BackupHistoryStore{
+		"file":   NewFileBackupStore(filepath.Join(dir, ".agollo")).(BackupHistoryStore),
+		"dir":    NewDirBackupStore(filepath.Join(dir, "dir")).(BackupHistoryStore),
+		"memory": NewMemoryBackupStore().(BackupHistoryStore),
+	}
+
+	for name, store := range stores {
+		history, err := store.History("application")
+		assert.Nil(t, err, name)
+		assert.Len(t, history, 0, name)
+
+		for _, releaseKey := range []string{"1", "2", "1", "3", "4"} {
+			backup := NewBackup("application", "test", "default", releaseKey, Configurations{"foo": releaseKey})
+			assert.Nil(t, store.SaveHistory("application", backup, 3), name)
+		}
+		// 备份与历史版本互不影响
+		assert.Nil(t, store.Save("application", NewBackup("application", "test", "default", "4", Configurations{})), name)
+
+		history, err = store.History("application")
+		assert.Nil(t, err, name)
+		var releaseKeys []string
+		for _, backup := range history {
+			releaseKeys = append(releaseKeys, backup.ReleaseKey)
+		}
+		assert.Equal(t, []string{"4", "3", "1"}, releaseKeys, name)
+		assert.Equal(t, Configurations{"foo": "1"}, history[2].Configurations, name)
+	}
+
+	// 并发保存时不丢失历史版本
+	for name, store := range stores {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(releaseKey string) {
+				defer wg.Done()
+				backup := NewBackup("concurrent", "test", "default", releaseKey, Configurations{"foo": releaseKey})
+				assert.Nil(t, store.SaveHistory("concurrent", backup, 10), name)
+			}(strconv.Itoa(i))
+		}
+		wg.Wait()
+
+		history, err := store.History("concurrent")
+		assert.Nil(t, err, name)
+		assert.Len(t, history, 10, name)
+	}
+
+	// 无法读取的历史版本移动到.corrupt后重新保存
+	dirStore := stores["dir"].(*dirBackupStore)
+	assert.Nil(t, ioutil.WriteFile(dirStore.historyPath("corrupt"), []byte("[corrupt"), 0644))
+	assert.Nil(t, dirStore.SaveHistory("corrupt", NewBackup("corrupt", "test", "default", "1", Configurations{}), 3))
+	history, err := dirStore.History("corrupt")
+	assert.Nil(t, err)
+	assert.Len(t, history, 1)
+	_, err = os.Stat(dirStore.historyPath("corrupt") + ".corrupt")
+	assert.Nil(t, err)
+}
+
+func TestAgolloRollback(t *testing.T) {
+	var (
+		lock           sync.Mutex
+		releaseKey     = 1
+		notificationID = 1
+	)
+	publish := func(newRelease bool) {
+		lock.Lock()
+		defer lock.Unlock()
+		if newRelease {
+			releaseKey++
+		}
+		notificationID++
+	}
+
+	client := &mockApolloClient{
+		notifications: func(configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, []Notification{{NamespaceName: "application", NotificationID: notificationID}}, nil
+		},
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, &Config{
+				NamespaceName:  "application",
+				Configurations: Configurations{"foo": strconv.Itoa(releaseKey)},
+				ReleaseKey:     strconv.Itoa(releaseKey),
+			}, nil
+		},
+	}
+
+	ag, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		PreloadNamespaces("application"),
+		WithBackupStore(NewMemoryBackupStore()),
+		BackupHistorySize(5),
+	)
+	assert.Nil(t, err)
+	a := ag.(*agollo)
+
+	publish(true)
+	a.longPoll(context.Background())
+	assert.Equal(t, "2", a.Get("foo"))
+
+	history, err := a.History("application")
+	assert.Nil(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, "2", history[0].ReleaseKey)
+
+	s := a.Subscribe("application")
+	assert.NotNil(t, a.Rollback("application", "404"))
+	assert.Nil(t, a.Rollback("application", "1"))
+	assert.Equal(t, "1", a.Get("foo"))
+	assert.Equal(t, NamespaceSourceRollback, a.Status()["application"].Source)
+	resp := receiveResponse(t, s.C())
+	assert.Equal(t, "1", resp.NewValue["foo"])
+
+	// 回滚时的release不会覆盖回滚的配置
+	publish(false)
+	a.longPoll(context.Background())
+	a.reloadNamespace(context.Background(), "application")
+	assert.Equal(t, "1", a.Get("foo"))
+
+	// 新的release解除回滚
+	publish(true)
+	a.longPoll(context.Background())
+	assert.Equal(t, "3", a.Get("foo"))
+	assert.Equal(t, NamespaceSourceRemote, a.Status()["application"].Source)
+
+	// namespace冻结时拒绝回滚
+	a.Freeze("application")
+	assert.Equal(t, ErrNamespaceFrozen, a.Rollback("application", "1"))
+	assert.Nil(t, a.Unfreeze("application"))
+	assert.Equal(t, "3", a.Get("foo"))
+
+	// 回滚的配置同样需要通过Validator校验
+	a.opts.Validators = map[string][]Validator{
+		"application": {func(oldValue, newValue Configurations) error {
+			if newValue["foo"] == "1" {
+				return errors.New("invalid foo")
+			}
+			return nil
+		}},
+	}
+	assert.True(t, isValidationError(a.Rollback("application", "1")))
+	assert.Equal(t, "3", a.Get("foo"))
+	assert.Equal(t, NamespaceSourceRemote, a.Status()["application"].Source)
+
+	// 不支持历史版本的BackupStore
+	a.opts.BackupStore = &backupStoreWithoutHistory{}
+	_, err = a.History("application")
+	assert.Equal(t, ErrBackupHistoryUnsupported, err)
+}
+
+type backupStoreWithoutHistory struct {
+	BackupStore
+}
@@ -0,0 +1,210 @@
+package agollo
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+type ChangeListenerOptions struct {
+	InterestedKeys        []string // 只关心的key，为空时不按key过滤
+	InterestedKeyPrefixes []string // 只关心的key前缀，为空时不按前缀过滤
+}
+
+type ChangeListenerOption func(*ChangeListenerOptions)
+
+func InterestedKeys(keys ...string) ChangeListenerOption {
+	return func(o *ChangeListenerOptions) {
+		o.InterestedKeys = append(o.InterestedKeys, keys...)
+	}
+}
+
+func InterestedKeyPrefixes(prefixes ...string) ChangeListenerOption {
+	return func(o *ChangeListenerOptions) {
+		o.InterestedKeyPrefixes = append(o.InterestedKeyPrefixes, prefixes...)
+	}
+}
+
+// ChangeListener 配置变更回调，每个ChangeListener拥有独立的goroutine及无界队列，
+// 回调执行缓慢不会阻塞轮训，也不会丢弃事件
+type ChangeListener struct {
+	agollo    *agollo
+	namespace string // 为空时监听所有namespace
+	fn        func(*ApolloResponse)
+	opts      ChangeListenerOptions
+
+	lock    sync.Mutex
+	pending []*ApolloResponse
+	notify  chan struct{}
+	done    chan struct{}
+
+	removeOnce sync.Once
+}
+
+// Remove 移除监听，已经进入队列但尚未回调的事件会被丢弃
+func (l *ChangeListener) Remove() {
+	l.removeOnce.Do(func() {
+		l.agollo.removeChangeListener(l)
+		close(l.done)
+	})
+}
+
+// interested 判断key是否是监听者关心的key
+func (l *ChangeListener) interested(key string) bool {
+	if len(l.opts.InterestedKeys) == 0 && len(l.opts.InterestedKeyPrefixes) == 0 {
+		return true
+	}
+
+	for _, k := range l.opts.InterestedKeys {
+		if k == key {
+			return true
+		}
+	}
+
+	for _, prefix := range l.opts.InterestedKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter 过滤掉不关心的变更，没有关心的变更时返回nil
+func (l *ChangeListener) filter(resp *ApolloResponse) *ApolloResponse {
+	var changes Changes
+	for _, change := range resp.Changes {
+		if l.interested(change.Key) {
+			changes = append(changes, change)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	filtered := *resp
+	filtered.Changes = changes
+	return &filtered
+}
+
+func (l *ChangeListener) push(resp *ApolloResponse) {
+	l.lock.Lock()
+	l.pending = append(l.pending, resp)
+	l.lock.Unlock()
+
+	select {
+	case l.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (l *ChangeListener) pop() []*ApolloResponse {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	pending := l.pending
+	l.pending = nil
+	return pending
+}
+
+func (l *ChangeListener) run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-l.notify:
+			l.dispatch(l.pop())
+		case <-stopCh:
+			// agollo停止时回调完已经进入队列的事件再退出
+			l.dispatch(l.pop())
+			return
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *ChangeListener) dispatch(responses []*ApolloResponse) {
+	for _, resp := range responses {
+		select {
+		case <-l.done:
+			return
+		default:
+		}
+		l.call(resp)
+	}
+}
+
+func (l *ChangeListener) call(resp *ApolloResponse) {
+	defer func() {
+		if r := recover(); r != nil {
+			l.agollo.log("Namespace", resp.Namespace, "Action", "ChangeListener", "Panic", r)
+		}
+	}()
+	l.fn(resp)
+}
+
+// AddChangeListener 添加配置变更回调，namespace为空时监听所有namespace，
+// 可以通过InterestedKeys、InterestedKeyPrefixes只关心部分key的变更，
+// 回调中的Changes只包含关心的key，没有关心的key变更时不会回调
+func (a *agollo) AddChangeListener(namespace string, fn func(*ApolloResponse), opts ...ChangeListenerOption) *ChangeListener {
+	l := &ChangeListener{
+		agollo: a,
+		fn:     fn,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&l.opts)
+	}
+
+	if namespace != "" {
+		l.namespace = a.opts.fixWatchNamespace(namespace)
+
+		// 非预加载以外的namespace,初始化基础meta信息,否则没有longpoll
+		ctx, cancel := a.withStopContext(context.Background())
+		defer cancel()
+		if err := a.initNamespace(ctx, namespace); err != nil {
+			a.log("Namespace", namespace, "Action", "AddChangeListener", "Error", err)
+		}
+	}
+
+	a.listenerLock.Lock()
+	if a.listeners == nil {
+		a.listeners = map[string][]*ChangeListener{}
+	}
+	a.listeners[l.namespace] = append(a.listeners[l.namespace], l)
+	a.listenerLock.Unlock()
+
+	a.goroutine(func() {
+		l.run(a.stopCh)
+	})
+
+	return l
+}
+
+func (a *agollo) removeChangeListener(l *ChangeListener) {
+	a.listenerLock.Lock()
+	defer a.listenerLock.Unlock()
+
+	listeners := a.listeners[l.namespace]
+	for i, listener := range listeners {
+		if listener == l {
+			a.listeners[l.namespace] = append(listeners[:i:i], listeners[i+1:]...)
+			break
+		}
+	}
+	if len(a.listeners[l.namespace]) == 0 {
+		delete(a.listeners, l.namespace)
+	}
+}
+
+func (a *agollo) notifyChangeListeners(resp *ApolloResponse) {
+	a.listenerLock.RLock()
+	var listeners []*ChangeListener
+	listeners = append(listeners, a.listeners[""]...)
+	listeners = append(listeners, a.listeners[a.opts.fixWatchNamespace(resp.Namespace)]...)
+	a.listenerLock.RUnlock()
+
+	for _, l := range listeners {
+		if filtered := l.filter(resp); filtered != nil {
+			l.push(filtered)
+		}
+	}
+}
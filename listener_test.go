@@ -0,0 +1,115 @@
+package agollo
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgolloChangeListener(t *testing.T) {
+	backupfile, err := ioutil.TempFile("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(backupfile.Name())
+
+	var (
+		lock           sync.Mutex
+		configurations = Configurations{"db.host": "a", "db.port": "1", "timeout": "1s"}
+		releaseKey     = 1
+	)
+	setConfig := func(conf Configurations) {
+		lock.Lock()
+		defer lock.Unlock()
+		configurations = conf
+		releaseKey++
+	}
+
+	client := &mockApolloClient{
+		notifications: func(configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, []Notification{{NamespaceName: "application", NotificationID: releaseKey}}, nil
+		},
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, &Config{
+				NamespaceName:  "application",
+				Configurations: configurations,
+				ReleaseKey:     strconv.Itoa(releaseKey),
+			}, nil
+		},
+	}
+
+	ag, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		PreloadNamespaces("application"),
+		BackupFile(backupfile.Name()),
+	)
+	assert.Nil(t, err)
+	a := ag.(*agollo)
+
+	var (
+		all     = make(chan *ApolloResponse, 10)
+		db      = make(chan *ApolloResponse, 10)
+		timeout = make(chan *ApolloResponse, 10)
+		block   = make(chan struct{})
+	)
+	a.AddChangeListener("", func(resp *ApolloResponse) {
+		// 回调阻塞不影响轮训及其他监听者
+		<-block
+		all <- resp
+	})
+	a.AddChangeListener("application", func(resp *ApolloResponse) {
+		db <- resp
+	}, InterestedKeyPrefixes("db."))
+	l := a.AddChangeListener("application.properties", func(resp *ApolloResponse) {
+		timeout <- resp
+	}, InterestedKeys("timeout"))
+
+	setConfig(Configurations{"db.host": "b", "db.port": "1", "timeout": "1s"})
+	a.longPoll(context.Background())
+	setConfig(Configurations{"db.host": "b", "db.port": "1", "timeout": "2s"})
+	a.longPoll(context.Background())
+
+	resp := receiveResponse(t, db)
+	assert.Equal(t, "application", resp.Namespace)
+	assert.Equal(t, Changes{{Type: ChangeTypeUpdate, Key: "db.host", Value: "b", OldValue: "a", NewValue: "b"}}, resp.Changes)
+
+	resp = receiveResponse(t, timeout)
+	assert.Equal(t, Changes{{Type: ChangeTypeUpdate, Key: "timeout", Value: "2s", OldValue: "1s", NewValue: "2s"}}, resp.Changes)
+
+	// 移除后不再回调
+	l.Remove()
+	setConfig(Configurations{"db.host": "b", "db.port": "1", "timeout": "3s"})
+	a.longPoll(context.Background())
+
+	close(block)
+	for i := 0; i < 3; i++ {
+		resp = receiveResponse(t, all)
+		assert.Len(t, resp.Changes, 1)
+	}
+	assert.Equal(t, "3s", resp.NewValue["timeout"])
+	assert.Len(t, db, 0)
+	assert.Len(t, timeout, 0)
+
+	assert.Nil(t, a.Shutdown(context.Background()))
+}
+
+func receiveResponse(t *testing.T, ch <-chan *ApolloResponse) *ApolloResponse {
+	t.Helper()
+	select {
+	case resp := <-ch:
+		return resp
+	case <-time.After(time.Second):
+		t.Fatal("change listener should be called")
+	}
+	return nil
+}
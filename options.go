@@ -18,28 +18,41 @@ var (
 )
 
 type Options struct {
-	AppID                      string               // appid
-	Cluster                    string               // 默认的集群名称，默认：default
-	DefaultNamespace           string               // Get时默认使用的命名空间，如果设置了该值，而不在PreloadNamespaces中，默认也会加入初始化逻辑中
-	PreloadNamespaces          []string             // 预加载命名空间，默认：为空
-	ApolloClient               ApolloClient         // apollo HTTP api实现
-	Logger                     Logger               // 日志实现类，可以设置自定义实现或者通过NewLogger()创建并设置有效的io.Writer，默认: ioutil.Discard
-	AutoFetchOnCacheMiss       bool                 // 自动获取非预设以外的Namespace的配置，默认：false
-	LongPollerInterval         time.Duration        // 轮训间隔时间，默认：1s
-	BackupFile                 string               // 备份文件存放地址，默认：.agollo
-	FailTolerantOnBackupExists bool                 // 服务器连接失败时允许读取备份，默认：false
-	Balancer                   Balancer             // ConfigServer负载均衡
-	EnableSLB                  bool                 // 启用ConfigServer负载均衡
-	RefreshIntervalInSecond    time.Duration        // ConfigServer刷新间隔
-	ClientOptions              []ApolloClientOption // 设置apollo HTTP api的配置项
-	EnableHeartBeat            bool                 // 是否允许兜底检查，默认：false
-	HeartBeatInterval          time.Duration        // 兜底检查间隔时间，默认：300s
+	AppID                      string                 // appid
+	Cluster                    string                 // 默认的集群名称，默认：DataCenter，DataCenter为空时为default
+	Env                        string                 // apollo的环境，例如：DEV、FAT、UAT、PRO，用于查找对应环境的meta server，默认：环境变量ENV或者server.properties中的env
+	DataCenter                 string                 // 所在的数据中心，默认：环境变量IDC或者server.properties中的idc
+	EnableClusterFallback      bool                   // namespace在Cluster中不存在时依次从ClusterFallback中的集群加载，默认：false
+	ClusterFallback            []string               // 客户端回退的集群列表，默认：DataCenter、default
+	DefaultNamespace           string                 // Get时默认使用的命名空间，如果设置了该值，而不在PreloadNamespaces中，默认也会加入初始化逻辑中
+	PreloadNamespaces          []string               // 预加载命名空间，默认：为空
+	ApolloClient               ApolloClient           // apollo HTTP api实现
+	Logger                     Logger                 // 日志实现类，可以设置自定义实现或者通过NewLogger()创建并设置有效的io.Writer，默认: ioutil.Discard
+	AutoFetchOnCacheMiss       bool                   // 自动获取非预设以外的Namespace的配置，默认：false
+	LongPollerInterval         time.Duration          // 轮训间隔时间，默认：1s
+	LongPollerSchedulePolicy   SchedulePolicy         // 长轮询连续失败时的等待策略，默认：从1s开始指数退避，最长120s
+	BackupFile                 string                 // 备份文件存放地址，默认：.agollo
+	BackupStore                BackupStore            // 备份的存储方式，默认：将所有namespace备份到BackupFile中
+	BackupMaxAge               time.Duration          // 容灾时允许使用的备份的最长时间，默认：0不限制
+	BackupKeyProvider          KeyProvider            // 设置后默认的备份文件使用AES-GCM加密
+	BackupAllowPlaintext       bool                   // 设置BackupKeyProvider后仍然允许读取未加密的旧备份，默认：false
+	BackupHistorySize          int                    // 每个namespace在本地保留的历史版本数量，用于Rollback，默认：0不保留
+	Validators                 map[string][]Validator // namespace的release生效前的校验
+	FailTolerantOnBackupExists bool                   // 服务器连接失败时允许读取备份，默认：false
+	Balancer                   Balancer               // ConfigServer负载均衡
+	EnableSLB                  bool                   // 启用ConfigServer负载均衡
+	RefreshIntervalInSecond    time.Duration          // ConfigServer刷新间隔
+	HealthCheckOptions         []HealthCheckOption    // 默认Balancer摘除及探测失败的ConfigServer的配置项
+	ClientOptions              []ApolloClientOption   // 设置apollo HTTP api的配置项
+	EnableHeartBeat            bool                   // 是否允许兜底检查，默认：false
+	HeartBeatInterval          time.Duration          // 兜底检查间隔时间，默认：300s
+	ConfigTypes                map[string]string      // namespace的配置格式，未设置的namespace根据后缀名判断，默认：properties
+	Comparer                   Comparer               // 计算配置变更时比较配置值的方式，默认：DeepEqualComparer
 }
 
 func newOptions(configServerURL, appID string, opts ...Option) (Options, error) {
 	var options = Options{
 		AppID:                      appID,
-		Cluster:                    defaultCluster,
 		ApolloClient:               NewApolloClient(),
 		Logger:                     NewLogger(),
 		AutoFetchOnCacheMiss:       defaultAutoFetchOnCacheMiss,
@@ -49,26 +62,64 @@ func newOptions(configServerURL, appID string, opts ...Option) (Options, error)
 		EnableSLB:                  defaultEnableSLB,
 		EnableHeartBeat:            defaultEnableHeartBeat,
 		HeartBeatInterval:          defaultHeartBeatInterval,
+		Comparer:                   DeepEqualComparer,
 	}
 	for _, opt := range opts {
 		opt(&options)
 	}
 
+	// 与java客户端相同，未指定集群时使用所在数据中心的同名集群
+	options.Env = getEnv(options.Env)
+	if options.DataCenter == "" {
+		options.DataCenter = getIDC()
+	}
+	if options.Cluster == "" {
+		options.Cluster = nonEmptyString(defaultCluster, options.DataCenter)
+	}
+	if options.DataCenter != "" {
+		options.ClientOptions = append(options.ClientOptions, WithDataCenter(options.DataCenter))
+	}
+	if options.EnableClusterFallback && len(options.ClusterFallback) == 0 {
+		options.ClusterFallback = []string{options.DataCenter, defaultCluster}
+	}
+
 	options.ApolloClient.Apply(options.ClientOptions...)
 
+	if options.LongPollerSchedulePolicy == nil {
+		options.LongPollerSchedulePolicy = NewExponentialSchedulePolicy(
+			defaultLongPollerFailMinDelay, defaultLongPollerFailMaxDelay, 0.2)
+	}
+
+	if options.BackupStore == nil {
+		var storeOpts []BackupStoreOption
+		if options.BackupKeyProvider != nil {
+			storeOpts = append(storeOpts, EncryptBackup(options.BackupKeyProvider))
+		}
+		if options.BackupAllowPlaintext {
+			storeOpts = append(storeOpts, AllowPlaintextBackup())
+		}
+		options.BackupStore = NewFileBackupStore(options.BackupFile, storeOpts...)
+	}
+
 	if options.Balancer == nil {
-		var b Balancer
+		var (
+			b                  Balancer
+			fetchConfigServers = toContextApolloClient(options.ApolloClient).GetConfigServersWithContext
+			// 默认通过/services/config接口主动探测ConfigServer
+			healthCheckOptions = append([]HealthCheckOption{
+				withProbe(configServerProbe(fetchConfigServers, appID)),
+			}, options.HealthCheckOptions...)
+		)
 		configServerURLs := getConfigServers(configServerURL)
 		if options.EnableSLB || len(configServerURLs) == 0 {
 			var err error
-			b, err = NewAutoFetchBalancer(configServerURL, appID,
-				options.ApolloClient.GetConfigServers,
-				options.RefreshIntervalInSecond, options.Logger)
+			b, err = newAutoFetchBalancer(getMetaServerURL(configServerURL, options.Env), appID, fetchConfigServers,
+				options.RefreshIntervalInSecond, options.Logger, healthCheckOptions...)
 			if err != nil {
 				return options, err
 			}
 		} else {
-			b = NewRoundRobin(configServerURLs)
+			b = NewRoundRobin(configServerURLs, healthCheckOptions...)
 		}
 		options.Balancer = b
 	}
@@ -88,10 +139,11 @@ func newOptions(configServerURL, appID string, opts ...Option) (Options, error)
 目前实现方式:
  0. 客户端显式传入ConfigServerURL
  2. Get from OS environment variable
+ 3. Get from server.properties(apollo.configService)
 
 未实现:
  1. Get from System Property
- 3. Get from server.properties
+
 https://github.com/ctripcorp/apollo/blob/master/apollo-client/src/main/java/com/ctrip/framework/apollo/internals/ConfigServiceLocator.java#L74
 */
 func getConfigServers(configServerURL string) []string {
@@ -99,6 +151,7 @@ func getConfigServers(configServerURL string) []string {
 	for _, url := range []string{
 		configServerURL,
 		os.Getenv("APOLLO_CONFIGSERVICE"),
+		getServerProperty("apollo.configService"),
 	} {
 		if url != "" {
 			urls = splitCommaSeparatedURL(url)
@@ -117,6 +170,44 @@ func Cluster(cluster string) Option {
 	}
 }
 
+// Env 设置apollo的环境，例如：DEV、FAT、UAT、PRO，未显式传入configServerURL时
+// 从环境变量{ENV}_META或者apollo-env.properties中的{env}.meta查找meta server
+func Env(env string) Option {
+	return func(o *Options) {
+		o.Env = env
+	}
+}
+
+// DataCenter 设置所在的数据中心，请求apollo时携带dataCenter参数，未设置Cluster时使用数据中心的同名集群
+func DataCenter(dataCenter string) Option {
+	return func(o *Options) {
+		o.DataCenter = dataCenter
+	}
+}
+
+// ClusterFallback namespace在Cluster中不存在(404)时，依次从clusters中的集群加载，
+// clusters为空时为DataCenter、default，实际加载的集群可以通过Status查看
+func ClusterFallback(clusters ...string) Option {
+	return func(o *Options) {
+		o.EnableClusterFallback = true
+		o.ClusterFallback = append(o.ClusterFallback, clusters...)
+	}
+}
+
+// clusters 加载namespace时依次尝试的集群，去掉空值及重复的集群
+func (o Options) clusters() []string {
+	clusters := []string{o.Cluster}
+	if !o.EnableClusterFallback {
+		return clusters
+	}
+	for _, cluster := range o.ClusterFallback {
+		if cluster != "" && !stringInSlice(cluster, clusters) {
+			clusters = append(clusters, cluster)
+		}
+	}
+	return clusters
+}
+
 func DefaultNamespace(defaultNamespace string) Option {
 	return func(o *Options) {
 		o.DefaultNamespace = defaultNamespace
@@ -153,6 +244,13 @@ func LongPollerInterval(i time.Duration) Option {
 	}
 }
 
+// LongPollerSchedulePolicy 设置长轮询连续失败时的等待策略，等待时间小于LongPollerInterval时使用LongPollerInterval
+func LongPollerSchedulePolicy(p SchedulePolicy) Option {
+	return func(o *Options) {
+		o.LongPollerSchedulePolicy = p
+	}
+}
+
 func EnableHeartBeat(b bool) Option {
 	return func(o *Options) {
 		o.EnableHeartBeat = b
@@ -171,12 +269,57 @@ func BackupFile(backupFile string) Option {
 	}
 }
 
+// WithBackupStore 设置备份的存储方式，例如：NewDirBackupStore、NewMemoryBackupStore，设置后BackupFile不再生效
+func WithBackupStore(store BackupStore) Option {
+	return func(o *Options) {
+		o.BackupStore = store
+	}
+}
+
+// BackupMaxAge 容灾时拒绝使用获取时间超过maxAge的备份，没有获取时间的旧版本备份同样会被拒绝
+func BackupMaxAge(maxAge time.Duration) Option {
+	return func(o *Options) {
+		o.BackupMaxAge = maxAge
+	}
+}
+
+// BackupEncryption 使用kp提供的密钥加密BackupFile，文件权限为0600，
+// 使用WithBackupStore时请通过EncryptBackup设置
+func BackupEncryption(kp KeyProvider) Option {
+	return func(o *Options) {
+		o.BackupKeyProvider = kp
+	}
+}
+
+// BackupPlaintextMigration 开启BackupEncryption后仍然允许读取未加密的旧备份，写入时会重新加密，
+// 迁移完成后请去掉。使用WithBackupStore时请通过AllowPlaintextBackup设置
+func BackupPlaintextMigration() Option {
+	return func(o *Options) {
+		o.BackupAllowPlaintext = true
+	}
+}
+
+// BackupHistorySize 每个namespace在本地保留最近size个不同release的配置，用于History及Rollback
+func BackupHistorySize(size int) Option {
+	return func(o *Options) {
+		o.BackupHistorySize = size
+	}
+}
+
 func FailTolerantOnBackupExists() Option {
 	return func(o *Options) {
 		o.FailTolerantOnBackupExists = true
 	}
 }
 
+// HealthCheck 设置默认Balancer摘除及探测失败的ConfigServer的方式，例如：
+// HealthCheck(EjectionTime(time.Second, time.Minute), ActiveProbe(5*time.Second, nil))
+func HealthCheck(opts ...HealthCheckOption) Option {
+	return func(o *Options) {
+		o.HealthCheckOptions = append(o.HealthCheckOptions, opts...)
+	}
+}
+
 func EnableSLB(b bool) Option {
 	return func(o *Options) {
 		o.EnableSLB = b
@@ -207,6 +350,74 @@ func WithClientOptions(opts ...ApolloClientOption) Option {
 	}
 }
 
+func NamespaceConfigType(namespace, configType string) Option {
+	return func(o *Options) {
+		if o.ConfigTypes == nil {
+			o.ConfigTypes = map[string]string{}
+		}
+		o.ConfigTypes[namespace] = configType
+	}
+}
+
+// WithComparer 设置计算配置变更时比较配置值的方式，例如：NormalizedComparer
+func WithComparer(comparer Comparer) Option {
+	return func(o *Options) {
+		o.Comparer = comparer
+	}
+}
+
+// WithValidator 新的release生效前使用validator校验namespace的配置，校验失败时保留旧的配置、不备份，
+// 并将*ValidationError发送到Start返回的errors channel中
+func WithValidator(namespace string, validator Validator) Option {
+	return func(o *Options) {
+		if o.Validators == nil {
+			o.Validators = map[string][]Validator{}
+		}
+		o.Validators[namespace] = append(o.Validators[namespace], validator)
+	}
+}
+
+// WithJSONSchema 新的release生效前使用schema校验json/yaml格式namespace解析后的content，
+// content解析失败或不符合schema时的处理同WithValidator，错误为SchemaErrors
+func WithJSONSchema(namespace string, schema *JSONSchema) Option {
+	return func(o *Options) {
+		WithValidator(namespace, jsonSchemaValidator(schema, func() string {
+			return o.getConfigType(namespace)
+		}))(o)
+	}
+}
+
+// getConfigType 获取namespace的配置格式，优先级顺序为：
+// namespace的后缀名 > NamespaceConfigType显式设置的格式 > properties
+func (o Options) getConfigType(namespace string) string {
+	if configType := getConfigTypeByExt(namespace); configType != "" {
+		return configType
+	}
+	if configType, found := o.ConfigTypes[namespace]; found && configType != "" {
+		return configType
+	}
+	return defaultConfigType
+}
+
+// remoteNamespace 访问apollo时使用的namespace名称，也作为本地缓存的key，
+// properties格式不需要后缀名，其他格式需要加上后缀名，
+// 例如: test.properties => test, routes(yaml) => routes.yaml
+func (o Options) remoteNamespace(namespace string) string {
+	configType := o.getConfigType(namespace)
+	namespace = trimConfigTypeExt(namespace)
+	if configType == defaultConfigType {
+		return namespace
+	}
+	return namespace + "." + configType
+}
+
+// fixWatchNamespace 带后缀名的namespace名称，用于匹配监听
+func (o Options) fixWatchNamespace(namespace string) string {
+	// fix: 传给apollo类似test.properties这种namespace
+	// 通知回来的NamespaceName却没有.properties后缀，追加.properties后缀来修正此问题
+	return trimConfigTypeExt(namespace) + "." + o.getConfigType(namespace)
+}
+
 type GetOptions struct {
 	// Get时，如果key不存在将返回此值
 	DefaultValue string
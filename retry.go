@@ -0,0 +1,173 @@
+package agollo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	defaultRetryableStatusCodes = []int{
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+	defaultLongPollerFailMinDelay = 1 * time.Second
+	defaultLongPollerFailMaxDelay = 120 * time.Second
+)
+
+// RetryPolicy ApolloClient非长轮询接口(configs、configfiles、services)的重试策略，
+// 网络错误或者返回RetryableStatusCodes中的状态码时，按指数退避并加上随机抖动后重试，
+// 返回200但响应无法解析时不重试。agollo加载配置时每次重试通过Balancer选择新的ConfigServer
+type RetryPolicy struct {
+	MaxAttempts          int           // 最多请求次数，包含第一次请求，<=1时不重试
+	InitialBackoff       time.Duration // 第一次重试前的等待时间
+	MaxBackoff           time.Duration // 等待时间的上限，0不限制
+	Multiplier           float64       // 每次重试等待时间的倍数，<1时为2
+	Jitter               float64       // 等待时间的随机抖动比例，取值0~1，例如0.2表示在[0.8, 1.2]倍之间随机
+	RetryableStatusCodes []int         // 需要重试的HTTP状态码，默认：429、500、502、503、504
+}
+
+// DefaultRetryPolicy 最多请求3次，等待时间从100ms开始翻倍，最长2s，抖动20%
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:          3,
+		InitialBackoff:       100 * time.Millisecond,
+		MaxBackoff:           2 * time.Second,
+		Multiplier:           2,
+		Jitter:               0.2,
+		RetryableStatusCodes: defaultRetryableStatusCodes,
+	}
+}
+
+// Backoff 第attempt次重试(从1开始)前的等待时间
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	return jitter(time.Duration(d), p.Jitter)
+}
+
+// decodeError 服务端返回200但是响应无法解析，重试不会得到不同的结果
+type decodeError struct {
+	err error
+}
+
+func (e *decodeError) Error() string { return e.err.Error() }
+
+func (e *decodeError) Unwrap() error { return e.err }
+
+func (p RetryPolicy) retryable(status int, err error) bool {
+	if err != nil {
+		var derr *decodeError
+		return !errors.As(err, &derr)
+	}
+	codes := p.RetryableStatusCodes
+	if codes == nil {
+		codes = defaultRetryableStatusCodes
+	}
+	for _, code := range codes {
+		if status == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retry 按照重试策略执行f，attempt从1开始，ctx结束时停止重试并返回最后一次的结果
+func (p RetryPolicy) retry(ctx context.Context, f func(attempt int) (int, error)) (status int, err error) {
+	for attempt := 1; ; attempt++ {
+		status, err = f(attempt)
+		if attempt >= p.MaxAttempts || !p.retryable(status, err) || ctx.Err() != nil {
+			return
+		}
+
+		timer := time.NewTimer(p.Backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+type serverSelectorKey struct{}
+
+// serverSelector 重试前选择新的server，failedURL、status、err为上一次请求的结果，返回下一次请求使用的server
+type serverSelector func(failedURL string, status int, err error) string
+
+// withServerSelector 重试时不再请求同一个server，而是通过selector选择新的server
+func withServerSelector(ctx context.Context, selector serverSelector) context.Context {
+	return context.WithValue(ctx, serverSelectorKey{}, selector)
+}
+
+func serverSelectorFromContext(ctx context.Context) serverSelector {
+	selector, _ := ctx.Value(serverSelectorKey{}).(serverSelector)
+	return selector
+}
+
+// jitter 在d的[1-factor, 1+factor]倍之间随机
+func jitter(d time.Duration, factor float64) time.Duration {
+	if factor <= 0 || d <= 0 {
+		return d
+	}
+	if factor > 1 {
+		factor = 1
+	}
+	return time.Duration(float64(d) * (1 - factor + 2*factor*rand.Float64()))
+}
+
+// SchedulePolicy 长轮询连续失败时的调度策略，参考Java客户端的SchedulePolicy
+type SchedulePolicy interface {
+	// Fail 记录一次失败，返回下一次请求前的等待时间
+	Fail() time.Duration
+	// Success 请求成功，重置等待时间
+	Success()
+}
+
+type exponentialSchedulePolicy struct {
+	min, max time.Duration
+	jitter   float64
+
+	lock  sync.Mutex
+	delay time.Duration
+}
+
+// NewExponentialSchedulePolicy 每次失败后等待时间翻倍，从min开始，最长max，并加上jitter比例的随机抖动，
+// 避免大量客户端在config service异常时同时重试
+func NewExponentialSchedulePolicy(min, max time.Duration, jitter float64) SchedulePolicy {
+	return &exponentialSchedulePolicy{min: min, max: max, jitter: jitter}
+}
+
+func (p *exponentialSchedulePolicy) Fail() time.Duration {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.delay == 0 {
+		p.delay = p.min
+	} else {
+		p.delay *= 2
+	}
+	if p.delay > p.max {
+		p.delay = p.max
+	}
+	return jitter(p.delay, p.jitter)
+}
+
+func (p *exponentialSchedulePolicy) Success() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.delay = 0
+}
@@ -0,0 +1,193 @@
+package agollo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	assert.Equal(t, 100*time.Millisecond, p.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, p.Backoff(2))
+	assert.Equal(t, 800*time.Millisecond, p.Backoff(4))
+	assert.Equal(t, time.Second, p.Backoff(10))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Backoff(1)
+		assert.True(t, d >= 50*time.Millisecond && d <= 150*time.Millisecond, d)
+	}
+}
+
+func TestApolloClientRetry(t *testing.T) {
+	var configsCalls, notificationsCalls int32
+	client := NewApolloClient(
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		WithDoer(&mockDoer{
+			do: func(req *http.Request) (*http.Response, error) {
+				if strings.HasPrefix(req.URL.Path, "/notifications/") {
+					atomic.AddInt32(&notificationsCalls, 1)
+					return newMockResponse(503, ""), nil
+				}
+
+				switch atomic.AddInt32(&configsCalls, 1) {
+				case 1:
+					return nil, errors.New("connection reset")
+				case 2:
+					return newMockResponse(503, ""), nil
+				}
+				return newMockResponse(200, `{"namespaceName":"application","configurations":{"foo":"bar"},"releaseKey":"1"}`), nil
+			},
+		}),
+	)
+
+	status, config, err := client.GetConfigsFromNonCache("localhost:8080", "test", "default", "application")
+	assert.Nil(t, err)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "bar", config.Configurations["foo"])
+	assert.Equal(t, int32(3), atomic.LoadInt32(&configsCalls))
+
+	// 超过最多请求次数时返回最后一次的结果
+	atomic.StoreInt32(&configsCalls, 0)
+	client.Apply(WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+	status, _, err = client.GetConfigsFromNonCache("localhost:8080", "test", "default", "application")
+	assert.Nil(t, err)
+	assert.Equal(t, 503, status)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&configsCalls))
+
+	// 长轮询不重试
+	status, _, _ = client.Notifications("localhost:8080", "test", "default",
+		[]Notification{{NamespaceName: "application", NotificationID: -1}})
+	assert.Equal(t, 503, status)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&notificationsCalls))
+
+	// ctx结束时停止重试
+	atomic.StoreInt32(&configsCalls, 0)
+	client.Apply(WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err = client.(ContextApolloClient).GetConfigsFromNonCacheWithContext(ctx, "localhost:8080", "test", "default", "application")
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&configsCalls))
+}
+
+func TestApolloClientRetryDecodeErrorAndSelector(t *testing.T) {
+	var hosts []string
+	client := NewApolloClient(
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		WithDoer(&mockDoer{
+			do: func(req *http.Request) (*http.Response, error) {
+				hosts = append(hosts, req.URL.Host)
+				switch req.URL.Host {
+				case "bad-json":
+					return newMockResponse(200, `{"configurations":`), nil
+				case "a":
+					return newMockResponse(503, ""), nil
+				}
+				return newMockResponse(200, `{"namespaceName":"application","configurations":{"foo":"bar"},"releaseKey":"1"}`), nil
+			},
+		}),
+	).(ContextApolloClient)
+
+	// 200但是响应无法解析时不重试
+	_, _, err := client.GetConfigsFromNonCacheWithContext(context.Background(), "bad-json", "test", "default", "application")
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"bad-json"}, hosts)
+
+	// 重试时通过selector选择新的server
+	hosts = nil
+	var failed []string
+	ctx := withServerSelector(context.Background(), func(failedURL string, status int, err error) string {
+		failed = append(failed, failedURL)
+		return "b"
+	})
+	status, config, err := client.GetConfigsFromNonCacheWithContext(ctx, "a", "test", "default", "application")
+	assert.Nil(t, err)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "bar", config.Configurations["foo"])
+	assert.Equal(t, []string{"a", "b"}, hosts)
+	assert.Equal(t, []string{"a"}, failed)
+}
+
+func TestExponentialSchedulePolicy(t *testing.T) {
+	p := NewExponentialSchedulePolicy(time.Second, 5*time.Second, 0)
+	assert.Equal(t, time.Second, p.Fail())
+	assert.Equal(t, 2*time.Second, p.Fail())
+	assert.Equal(t, 4*time.Second, p.Fail())
+	assert.Equal(t, 5*time.Second, p.Fail())
+	p.Success()
+	assert.Equal(t, time.Second, p.Fail())
+}
+
+func TestAgolloLongPollBackoff(t *testing.T) {
+	status := 500
+	client := &mockApolloClient{
+		notifications: func(configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error) {
+			return status, nil, nil
+		},
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			return 200, &Config{NamespaceName: "application", Configurations: Configurations{}, ReleaseKey: "1"}, nil
+		},
+	}
+
+	ag, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		PreloadNamespaces("application"),
+		WithBackupStore(NewMemoryBackupStore()),
+		LongPollerInterval(2*time.Second),
+		LongPollerSchedulePolicy(NewExponentialSchedulePolicy(time.Second, 10*time.Second, 0)),
+	)
+	assert.Nil(t, err)
+	a := ag.(*agollo)
+
+	// 等待时间不小于LongPollerInterval
+	assert.Equal(t, 2*time.Second, a.nextLongPollInterval(a.longPoll(context.Background())))
+	assert.Equal(t, 2*time.Second, a.nextLongPollInterval(a.longPoll(context.Background())))
+	assert.Equal(t, 4*time.Second, a.nextLongPollInterval(a.longPoll(context.Background())))
+
+	status = 304
+	assert.Equal(t, 2*time.Second, a.nextLongPollInterval(a.longPoll(context.Background())))
+
+	status = 500
+	assert.Equal(t, 2*time.Second, a.nextLongPollInterval(a.longPoll(context.Background())))
+	assert.Equal(t, 2*time.Second, a.nextLongPollInterval(a.longPoll(context.Background())))
+
+	// 401、403等同样退避
+	status = 403
+	assert.Equal(t, 4*time.Second, a.nextLongPollInterval(a.longPoll(context.Background())))
+}
+
+func TestAgolloRetryNextConfigServer(t *testing.T) {
+	var configsHosts []string
+	ag, err := New("a,b", "test",
+		PreloadNamespaces("application"),
+		WithBackupStore(NewMemoryBackupStore()),
+		WithClientOptions(
+			WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+			WithDoer(&mockDoer{
+				do: func(req *http.Request) (*http.Response, error) {
+					if !strings.HasPrefix(req.URL.Path, "/configs/") {
+						return newMockResponse(304, ""), nil
+					}
+					configsHosts = append(configsHosts, req.URL.Host)
+					if req.URL.Host == "a" {
+						return newMockResponse(503, ""), nil
+					}
+					return newMockResponse(200, `{"namespaceName":"application","configurations":{"foo":"bar"},"releaseKey":"1"}`), nil
+				},
+			}),
+		),
+	)
+	assert.Nil(t, err)
+
+	// a失败后重试请求b
+	assert.Equal(t, "bar", ag.Get("foo"))
+	assert.Equal(t, []string{"a", "b"}, configsHosts)
+}
@@ -0,0 +1,387 @@
+package agollo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSONSchema JSON Schema的子集，支持的关键字：
+// type、enum、const、properties、required、additionalProperties、items、
+// minimum、maximum、exclusiveMinimum、exclusiveMaximum、minLength、maxLength、pattern、minItems、maxItems，
+// 以及不影响校验的$schema、$id、$comment、title、description、default、examples。
+// 使用其他关键字(例如$ref、allOf、format)时CompileJSONSchema返回错误，避免约束被静默忽略
+type JSONSchema struct {
+	types                []string
+	enum                 []interface{}
+	constValue           interface{}
+	hasConst             bool
+	properties           map[string]*JSONSchema
+	required             []string
+	additionalProperties *JSONSchema
+	noAdditional         bool // additionalProperties: false
+	items                *JSONSchema
+	minimum              *float64
+	maximum              *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+	minLength            *int
+	maxLength            *int
+	pattern              *regexp.Regexp
+	minItems             *int
+	maxItems             *int
+}
+
+type jsonSchemaDefinition struct {
+	Type                 json.RawMessage            `json:"type"`
+	Enum                 []interface{}              `json:"enum"`
+	Const                json.RawMessage            `json:"const"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+	Items                json.RawMessage            `json:"items"`
+	Minimum              *float64                   `json:"minimum"`
+	Maximum              *float64                   `json:"maximum"`
+	ExclusiveMinimum     *float64                   `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64                   `json:"exclusiveMaximum"`
+	MinLength            *int                       `json:"minLength"`
+	MaxLength            *int                       `json:"maxLength"`
+	Pattern              string                     `json:"pattern"`
+	MinItems             *int                       `json:"minItems"`
+	MaxItems             *int                       `json:"maxItems"`
+}
+
+// supportedJSONSchemaKeywords 支持的关键字，value为false的关键字只作为注释，不参与校验
+var supportedJSONSchemaKeywords = map[string]bool{
+	"type":                 true,
+	"enum":                 true,
+	"const":                true,
+	"properties":           true,
+	"required":             true,
+	"additionalProperties": true,
+	"items":                true,
+	"minimum":              true,
+	"maximum":              true,
+	"exclusiveMinimum":     true,
+	"exclusiveMaximum":     true,
+	"minLength":            true,
+	"maxLength":            true,
+	"pattern":              true,
+	"minItems":             true,
+	"maxItems":             true,
+	"$schema":              false,
+	"$id":                  false,
+	"$comment":             false,
+	"title":                false,
+	"description":          false,
+	"default":              false,
+	"examples":             false,
+}
+
+// CompileJSONSchema 解析JSON Schema
+func CompileJSONSchema(schema string) (*JSONSchema, error) {
+	return compileJSONSchema([]byte(schema))
+}
+
+// MustCompileJSONSchema 同CompileJSONSchema，解析失败时panic
+func MustCompileJSONSchema(schema string) *JSONSchema {
+	s, err := CompileJSONSchema(schema)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func compileJSONSchema(data []byte) (*JSONSchema, error) {
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return nil, fmt.Errorf("agollo: invalid json schema: %v", err)
+	}
+	var unsupported []string
+	for keyword := range keywords {
+		if _, ok := supportedJSONSchemaKeywords[keyword]; !ok {
+			unsupported = append(unsupported, keyword)
+		}
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return nil, fmt.Errorf("agollo: unsupported json schema keywords: %s", strings.Join(unsupported, ", "))
+	}
+
+	var def jsonSchemaDefinition
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("agollo: invalid json schema: %v", err)
+	}
+
+	s := &JSONSchema{
+		enum:             def.Enum,
+		required:         def.Required,
+		minimum:          def.Minimum,
+		maximum:          def.Maximum,
+		exclusiveMinimum: def.ExclusiveMinimum,
+		exclusiveMaximum: def.ExclusiveMaximum,
+		minLength:        def.MinLength,
+		maxLength:        def.MaxLength,
+		minItems:         def.MinItems,
+		maxItems:         def.MaxItems,
+	}
+
+	if len(def.Type) > 0 {
+		var t string
+		if err := json.Unmarshal(def.Type, &t); err == nil {
+			s.types = []string{t}
+		} else if err := json.Unmarshal(def.Type, &s.types); err != nil {
+			return nil, fmt.Errorf("agollo: invalid json schema type: %s", def.Type)
+		}
+	}
+
+	if len(def.Const) > 0 {
+		s.hasConst = true
+		if err := json.Unmarshal(def.Const, &s.constValue); err != nil {
+			return nil, err
+		}
+	}
+
+	if def.Pattern != "" {
+		pattern, err := regexp.Compile(def.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("agollo: invalid json schema pattern: %v", err)
+		}
+		s.pattern = pattern
+	}
+
+	if len(def.Properties) > 0 {
+		s.properties = make(map[string]*JSONSchema, len(def.Properties))
+		for name, raw := range def.Properties {
+			property, err := compileJSONSchema(raw)
+			if err != nil {
+				return nil, err
+			}
+			s.properties[name] = property
+		}
+	}
+
+	if len(def.AdditionalProperties) > 0 {
+		var allowed bool
+		if err := json.Unmarshal(def.AdditionalProperties, &allowed); err == nil {
+			s.noAdditional = !allowed
+		} else {
+			additional, err := compileJSONSchema(def.AdditionalProperties)
+			if err != nil {
+				return nil, err
+			}
+			s.additionalProperties = additional
+		}
+	}
+
+	if len(def.Items) > 0 {
+		items, err := compileJSONSchema(def.Items)
+		if err != nil {
+			return nil, err
+		}
+		s.items = items
+	}
+
+	return s, nil
+}
+
+// SchemaError 配置中不符合JSON Schema的位置，Path例如：$.db.hosts[0]
+type SchemaError struct {
+	Path    string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// SchemaErrors 配置中所有不符合JSON Schema的位置
+type SchemaErrors []*SchemaError
+
+func (es SchemaErrors) Error() string {
+	msgs := make([]string, 0, len(es))
+	for _, e := range es {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate 校验json/yaml解析后的值，不符合时返回SchemaErrors
+func (s *JSONSchema) Validate(v interface{}) error {
+	var errs SchemaErrors
+	s.validate("$", v, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (s *JSONSchema) validate(path string, v interface{}, errs *SchemaErrors) {
+	addError := func(format string, args ...interface{}) {
+		*errs = append(*errs, &SchemaError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.types) > 0 && !s.matchType(v) {
+		addError("expected %s, got %s", strings.Join(s.types, " or "), jsonTypeOf(v))
+		return
+	}
+
+	if len(s.enum) > 0 {
+		var found bool
+		for _, e := range s.enum {
+			if jsonValueEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			addError("value %v is not one of %v", v, s.enum)
+		}
+	}
+
+	if s.hasConst && !jsonValueEqual(s.constValue, v) {
+		addError("value %v should be %v", v, s.constValue)
+	}
+
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, found := vv[name]; !found {
+				addError("missing required property %q", name)
+			}
+		}
+
+		keys := make([]string, 0, len(vv))
+		for k := range vv {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			propertyPath := path + "." + k
+			if property, found := s.properties[k]; found {
+				property.validate(propertyPath, vv[k], errs)
+			} else if s.noAdditional {
+				*errs = append(*errs, &SchemaError{Path: propertyPath, Message: "additional property is not allowed"})
+			} else if s.additionalProperties != nil {
+				s.additionalProperties.validate(propertyPath, vv[k], errs)
+			}
+		}
+	case []interface{}:
+		if s.minItems != nil && len(vv) < *s.minItems {
+			addError("should have at least %d items, got %d", *s.minItems, len(vv))
+		}
+		if s.maxItems != nil && len(vv) > *s.maxItems {
+			addError("should have at most %d items, got %d", *s.maxItems, len(vv))
+		}
+		if s.items != nil {
+			for i, item := range vv {
+				s.items.validate(path+"["+strconv.Itoa(i)+"]", item, errs)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(vv)
+		if s.minLength != nil && length < *s.minLength {
+			addError("length should be at least %d, got %d", *s.minLength, length)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			addError("length should be at most %d, got %d", *s.maxLength, length)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(vv) {
+			addError("%q does not match pattern %q", vv, s.pattern.String())
+		}
+	default:
+		f, ok := jsonNumber(v)
+		if !ok {
+			return
+		}
+		if s.minimum != nil && f < *s.minimum {
+			addError("%v should be >= %v", v, *s.minimum)
+		}
+		if s.maximum != nil && f > *s.maximum {
+			addError("%v should be <= %v", v, *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && f <= *s.exclusiveMinimum {
+			addError("%v should be > %v", v, *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && f >= *s.exclusiveMaximum {
+			addError("%v should be < %v", v, *s.exclusiveMaximum)
+		}
+	}
+}
+
+func (s *JSONSchema) matchType(v interface{}) bool {
+	actual := jsonTypeOf(v)
+	for _, t := range s.types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonTypeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+
+	if f, ok := jsonNumber(v); ok {
+		if f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// jsonNumber json解析的数字为float64，yaml解析的整数为int
+func jsonNumber(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func jsonValueEqual(a, b interface{}) bool {
+	fa, aok := jsonNumber(a)
+	fb, bok := jsonNumber(b)
+	if aok || bok {
+		return aok && bok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// jsonSchemaValidator json/yaml/xml格式的namespace校验解析后的content，
+// content解析失败时同样返回错误，其他格式的namespace将配置作为object校验
+func jsonSchemaValidator(schema *JSONSchema, configType func() string) Validator {
+	return func(oldValue, newValue Configurations) error {
+		v, structured, err := parseDocument(configType(), newValue)
+		if err != nil {
+			return SchemaErrors{{Path: "$", Message: err.Error()}}
+		}
+		if !structured {
+			v = map[string]interface{}(newValue)
+		}
+		return schema.Validate(v)
+	}
+}
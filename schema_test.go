@@ -0,0 +1,171 @@
+package agollo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["db", "hosts"],
+	"additionalProperties": false,
+	"properties": {
+		"db": {
+			"type": "object",
+			"required": ["port"],
+			"properties": {
+				"port": {"type": "integer", "minimum": 1, "maximum": 65535},
+				"driver": {"enum": ["mysql", "postgres"]},
+				"ratio": {"type": "number", "exclusiveMaximum": 1}
+			}
+		},
+		"hosts": {
+			"type": "array",
+			"minItems": 1,
+			"items": {"type": "string", "pattern": "^[a-z.]+$"}
+		},
+		"debug": {"type": ["boolean", "null"]}
+	}
+}`
+
+func TestJSONSchemaValidate(t *testing.T) {
+	schema, err := CompileJSONSchema(testSchema)
+	assert.Nil(t, err)
+
+	var tests = []struct {
+		Content string
+		Paths   []string
+	}{
+		{
+			Content: `{"db": {"port": 3306, "driver": "mysql", "ratio": 0.5}, "hosts": ["a.com"], "debug": null}`,
+		},
+		{
+			Content: `{"db": {"port": "3306"}, "hosts": ["a.com"]}`,
+			Paths:   []string{"$.db.port"},
+		},
+		{
+			Content: `{"db": {"port": 70000, "driver": "oracle", "ratio": 1}, "hosts": ["A.com", "b.com", 1]}`,
+			Paths:   []string{"$.db.driver", "$.db.port", "$.db.ratio", "$.hosts[0]", "$.hosts[2]"},
+		},
+		{
+			Content: `{"db": {}, "hosts": [], "foo": 1}`,
+			Paths:   []string{"$.db", "$.foo", "$.hosts"},
+		},
+		{
+			Content: `[]`,
+			Paths:   []string{"$"},
+		},
+	}
+
+	for _, test := range tests {
+		v, _, err := parseDocument(ConfigTypeJSON, Configurations{"content": test.Content})
+		assert.Nil(t, err)
+
+		err = schema.Validate(v)
+		if len(test.Paths) == 0 {
+			assert.Nil(t, err, test.Content)
+			continue
+		}
+
+		var errs SchemaErrors
+		assert.True(t, errors.As(err, &errs), test.Content)
+		var paths []string
+		for _, e := range errs {
+			paths = append(paths, e.Path)
+		}
+		assert.Equal(t, test.Paths, paths, test.Content)
+	}
+
+	// yaml解析的整数为int
+	v, _, err := parseDocument(ConfigTypeYAML, Configurations{"content": "db:\n  port: 3306\nhosts:\n  - a.com\n"})
+	assert.Nil(t, err)
+	assert.Nil(t, schema.Validate(v))
+
+	_, err = CompileJSONSchema(`{"type": 1}`)
+	assert.NotNil(t, err)
+	_, err = CompileJSONSchema(`{"pattern": "("}`)
+	assert.NotNil(t, err)
+
+	// 不支持的关键字在解析时报错，注释类的关键字可以使用
+	_, err = CompileJSONSchema(`{"type": "object", "allOf": [{"required": ["a"]}], "$ref": "#/definitions/a"}`)
+	assert.EqualError(t, err, "agollo: unsupported json schema keywords: $ref, allOf")
+	_, err = CompileJSONSchema(`{"properties": {"host": {"type": "string", "format": "hostname"}}}`)
+	assert.EqualError(t, err, "agollo: unsupported json schema keywords: format")
+	_, err = CompileJSONSchema(`{"$schema": "http://json-schema.org/draft-07/schema#", "title": "config", "description": "desc", "default": {}}`)
+	assert.Nil(t, err)
+}
+
+func TestAgolloWithJSONSchema(t *testing.T) {
+	var (
+		lock       sync.Mutex
+		releaseKey = 1
+		content    = "db:\n  port: 3306\nhosts:\n  - a.com\n"
+	)
+	publish := func(c string) {
+		lock.Lock()
+		defer lock.Unlock()
+		releaseKey++
+		content = c
+	}
+
+	client := &mockApolloClient{
+		notifications: func(configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, []Notification{{NamespaceName: "config.yaml", NotificationID: releaseKey}}, nil
+		},
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, &Config{
+				NamespaceName:  "config.yaml",
+				Configurations: Configurations{"content": content},
+				ReleaseKey:     strconv.Itoa(releaseKey),
+			}, nil
+		},
+	}
+
+	ag, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		PreloadNamespaces("config.yaml"),
+		WithBackupStore(NewMemoryBackupStore()),
+		WithJSONSchema("config.yaml", MustCompileJSONSchema(testSchema)),
+	)
+	assert.Nil(t, err)
+	a := ag.(*agollo)
+	assert.Equal(t, "3306", a.Get("db.port", WithNamespace("config.yaml")))
+
+	errorsCh := make(chan *LongPollerError, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			errorsCh <- <-a.errorsCh
+		}
+	}()
+	time.Sleep(10 * time.Millisecond) // 等待开始接收errors
+
+	// 不符合schema
+	publish("db:\n  port: 0\nhosts: []\n")
+	a.longPoll(context.Background())
+	lerr := <-errorsCh
+	var errs SchemaErrors
+	assert.True(t, errors.As(lerr.Err, &errs))
+	assert.Equal(t, "$.db.port: 0 should be >= 1; $.hosts: should have at least 1 items, got 0", errs.Error())
+
+	// yaml格式错误
+	publish("db:\n  port: 3306\n hosts: - a.com\n")
+	a.longPoll(context.Background())
+	lerr = <-errorsCh
+	assert.True(t, errors.As(lerr.Err, &errs))
+	assert.Equal(t, "$", errs[0].Path)
+	assert.Equal(t, "3306", a.Get("db.port", WithNamespace("config.yaml")))
+
+	publish("db:\n  port: 3307\nhosts:\n  - a.com\n")
+	a.longPoll(context.Background())
+	assert.Equal(t, "3307", a.Get("db.port", WithNamespace("config.yaml")))
+}
@@ -0,0 +1,168 @@
+package agollo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type NamespaceSource string
+
+const (
+	NamespaceSourceEmpty    NamespaceSource = "empty"    // 尚未成功加载过配置
+	NamespaceSourceRemote   NamespaceSource = "remote"   // 配置来自apollo服务端
+	NamespaceSourceBackup   NamespaceSource = "backup"   // 配置来自本地备份
+	NamespaceSourceRollback NamespaceSource = "rollback" // 配置来自Rollback回滚的历史版本
+)
+
+// NamespaceStatus namespace当前配置的来源及最近一次加载的情况
+type NamespaceStatus struct {
+	Namespace  string
+	Source     NamespaceSource
+	Cluster    string // 配置实际所在的集群，服务端或者ClusterFallback回退时与Options.Cluster不同
+	ReleaseKey string
+	LastError  error     // 最近一次加载失败的原因，加载成功后清空
+	UpdatedAt  time.Time // 最近一次更新缓存的时间
+	FetchedAt  time.Time // 当前配置从apollo获取的时间，来自备份时为备份的获取时间
+
+	Frozen            bool   // 是否已冻结
+	PendingReleaseKey string // 冻结期间收到的尚未生效的release
+}
+
+// Age 当前配置距离从apollo获取时的时间，获取时间未知时(例如旧版本的备份)返回-1
+func (s NamespaceStatus) Age() time.Duration {
+	if s.FetchedAt.IsZero() {
+		return -1
+	}
+	return time.Since(s.FetchedAt)
+}
+
+// InitNamespaceError 初始化时加载namespace失败的错误集合
+type InitNamespaceError struct {
+	Errors map[string]error // key: namespace value: error
+}
+
+func (e *InitNamespaceError) Error() string {
+	var namespaces []string
+	for namespace := range e.Errors {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	var msgs []string
+	for _, namespace := range namespaces {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", namespace, e.Errors[namespace]))
+	}
+	return "init namespace failed: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap 返回按namespace排序后的第一个错误，兼容errors.Is/errors.As
+func (e *InitNamespaceError) Unwrap() error {
+	var (
+		first string
+		err   error
+	)
+	for namespace, nerr := range e.Errors {
+		if err == nil || namespace < first {
+			first, err = namespace, nerr
+		}
+	}
+	return err
+}
+
+func statusError(status int, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("apollo server response status: %d", status)
+}
+
+func (a *agollo) updateStatus(namespace string, update func(*NamespaceStatus)) {
+	a.statusLock.Lock()
+	if a.status == nil {
+		a.status = map[string]*NamespaceStatus{}
+	}
+	s, found := a.status[namespace]
+	if !found {
+		s = &NamespaceStatus{Namespace: namespace, Source: NamespaceSourceEmpty}
+		a.status[namespace] = s
+	}
+	update(s)
+	a.statusLock.Unlock()
+
+	a.checkReady()
+}
+
+func (a *agollo) setLoaded(namespace string, source NamespaceSource, releaseKey string, fetchedAt time.Time, err error) {
+	a.updateStatus(namespace, func(s *NamespaceStatus) {
+		s.Source = source
+		s.ReleaseKey = releaseKey
+		s.LastError = err
+		s.UpdatedAt = time.Now()
+		s.FetchedAt = fetchedAt
+	})
+}
+
+// confirmRelease 服务端返回304时调用，从备份恢复的release被服务端确认后配置来源改为服务端
+func (a *agollo) confirmRelease(namespace, releaseKey string) {
+	a.updateStatus(namespace, func(s *NamespaceStatus) {
+		if s.Source == NamespaceSourceBackup && s.ReleaseKey == releaseKey {
+			s.Source = NamespaceSourceRemote
+			s.UpdatedAt = time.Now()
+			s.FetchedAt = time.Now()
+		}
+		s.LastError = nil
+	})
+}
+
+func (a *agollo) setLastError(namespace string, err error) {
+	a.updateStatus(namespace, func(s *NamespaceStatus) {
+		s.LastError = err
+	})
+}
+
+// checkReady PreloadNamespaces全部从服务端或者备份中加载成功后触发Ready
+func (a *agollo) checkReady() {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+
+	for _, namespace := range a.opts.PreloadNamespaces {
+		s, found := a.status[a.opts.remoteNamespace(namespace)]
+		if !found || s.Source == NamespaceSourceEmpty {
+			return
+		}
+	}
+
+	a.readyOnce.Do(func() {
+		close(a.readyCh)
+	})
+}
+
+// Ready PreloadNamespaces全部从服务端加载成功(开启FailTolerantOnBackupExists时也可以是从备份加载)后关闭
+func (a *agollo) Ready() <-chan struct{} {
+	return a.readyCh
+}
+
+// WaitReady 等待Ready，ctx结束时返回ctx.Err()
+func (a *agollo) WaitReady(ctx context.Context) error {
+	select {
+	case <-a.readyCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status 返回所有已初始化的namespace的状态
+func (a *agollo) Status() map[string]NamespaceStatus {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+
+	status := make(map[string]NamespaceStatus, len(a.status))
+	for namespace, s := range a.status {
+		status[namespace] = *s
+	}
+	return status
+}
@@ -0,0 +1,102 @@
+package agollo
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgolloReadyAndStatus(t *testing.T) {
+	backupfile, err := ioutil.TempFile("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(backupfile.Name())
+
+	configs := map[string]*Config{
+		"application": {NamespaceName: "application", Configurations: Configurations{"foo": "bar"}, ReleaseKey: "1"},
+		"backup":      {NamespaceName: "backup", Configurations: Configurations{"foo": "baz"}, ReleaseKey: "2"},
+	}
+	newClient := func(failed map[string]error) *mockApolloClient {
+		return &mockApolloClient{
+			notifications: func(configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error) {
+				return 304, nil, nil
+			},
+			getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+				if err, ok := failed[namespace]; ok {
+					return 500, nil, err
+				}
+				return 200, configs[namespace], nil
+			},
+		}
+	}
+
+	// 正常从服务端加载并写入备份
+	a, err := New("localhost:8080", "test",
+		WithApolloClient(newClient(nil)),
+		PreloadNamespaces("application", "backup"),
+		BackupFile(backupfile.Name()),
+	)
+	assert.Nil(t, err)
+	select {
+	case <-a.Ready():
+	default:
+		t.Fatal("agollo should be ready")
+	}
+	assert.Equal(t, NamespaceSourceRemote, a.Status()["application"].Source)
+	assert.Equal(t, "1", a.Status()["application"].ReleaseKey)
+	assert.Nil(t, a.Status()["application"].LastError)
+
+	// backup从备份中加载，missing加载失败
+	var (
+		backupErr  = errors.New("backup error")
+		missingErr = errors.New("missing error")
+	)
+	a, err = New("localhost:8080", "test",
+		WithApolloClient(newClient(map[string]error{"backup": backupErr, "missing": missingErr})),
+		PreloadNamespaces("application", "backup", "missing"),
+		BackupFile(backupfile.Name()),
+		FailTolerantOnBackupExists(),
+	)
+	initErr, ok := err.(*InitNamespaceError)
+	if !ok {
+		t.Fatalf("expected *InitNamespaceError, got %v", err)
+	}
+	assert.Equal(t, map[string]error{"missing": missingErr}, initErr.Errors)
+	assert.True(t, errors.Is(err, missingErr))
+	assert.Equal(t, "baz", a.GetNameSpace("backup")["foo"])
+
+	status := a.Status()
+	assert.Equal(t, NamespaceSourceRemote, status["application"].Source)
+	assert.Equal(t, NamespaceSourceBackup, status["backup"].Source)
+	assert.Equal(t, backupErr, status["backup"].LastError)
+	assert.Equal(t, NamespaceSourceEmpty, status["missing"].Source)
+	assert.Equal(t, missingErr, status["missing"].LastError)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, a.WaitReady(ctx))
+
+	// 服务端恢复后返回304确认了备份中的release，来源改为服务端
+	a.(*agollo).client = toContextApolloClient(&mockApolloClient{
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			var options NotificationsOptions
+			for _, opt := range opts {
+				opt(&options)
+			}
+			assert.Equal(t, "2", options.ReleaseKey)
+			return 304, nil, nil
+		},
+	})
+	_, _, err = a.(*agollo).reloadNamespace(context.Background(), "backup")
+	assert.Nil(t, err)
+	status = a.Status()
+	assert.Equal(t, NamespaceSourceRemote, status["backup"].Source)
+	assert.Equal(t, "2", status["backup"].ReleaseKey)
+	assert.Nil(t, status["backup"].LastError)
+}
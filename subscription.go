@@ -0,0 +1,258 @@
+package agollo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+var defaultSubscriptionBufferSize = 16
+
+// OverflowPolicy 订阅者缓冲区满时的处理策略
+type OverflowPolicy int
+
+const (
+	// OverflowBlock 阻塞轮训直到订阅者消费、订阅关闭或者agollo停止，不会丢弃事件
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest 丢弃缓冲区中最早的事件
+	OverflowDropOldest
+	// OverflowCoalesce 将缓冲区中同一个namespace的事件与新事件合并成一个事件，
+	// OldValue为最早事件的OldValue，NewValue为最新的配置
+	OverflowCoalesce
+)
+
+type SubscriptionOptions struct {
+	BufferSize     int            // channel缓冲区大小，默认：16
+	OverflowPolicy OverflowPolicy // 缓冲区满时的处理策略，默认：OverflowBlock
+}
+
+type SubscriptionOption func(*SubscriptionOptions)
+
+func SubscriptionBufferSize(size int) SubscriptionOption {
+	return func(o *SubscriptionOptions) {
+		o.BufferSize = size
+	}
+}
+
+func SubscriptionOverflowPolicy(policy OverflowPolicy) SubscriptionOption {
+	return func(o *SubscriptionOptions) {
+		o.OverflowPolicy = policy
+	}
+}
+
+// Subscription 每个订阅者拥有独立的channel，多个订阅者订阅同一个namespace时互不影响
+type Subscription struct {
+	agollo    *agollo
+	namespace string // 为空时订阅所有namespace
+	opts      SubscriptionOptions
+
+	lock      sync.Mutex
+	ch        chan *ApolloResponse
+	closed    bool
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   uint64
+}
+
+// C 返回接收配置变更的channel，Close或者Shutdown之后channel会被关闭
+func (s *Subscription) C() <-chan *ApolloResponse {
+	return s.ch
+}
+
+// Dropped 返回因缓冲区满被丢弃或者合并的事件数量
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close 取消订阅并关闭channel
+func (s *Subscription) Close() {
+	s.agollo.removeSubscription(s)
+	s.close()
+}
+
+func (s *Subscription) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.closed = true
+		close(s.ch)
+	})
+}
+
+func (s *Subscription) send(resp *ApolloResponse, stopCh <-chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.ch <- resp:
+		return
+	default:
+	}
+
+	switch s.opts.OverflowPolicy {
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.ch <- resp:
+				return
+			default:
+			}
+
+			select {
+			case <-s.ch:
+				atomic.AddUint64(&s.dropped, 1)
+			default:
+			}
+		}
+	case OverflowCoalesce:
+		responses := s.coalesce(append(s.drain(), resp))
+		for _, resp := range responses {
+			s.ch <- resp
+		}
+	default:
+		select {
+		case s.ch <- resp:
+		case <-s.done:
+		case <-stopCh:
+		}
+	}
+}
+
+// drain 取出缓冲区中所有的事件
+func (s *Subscription) drain() []*ApolloResponse {
+	var responses []*ApolloResponse
+	for {
+		select {
+		case resp := <-s.ch:
+			responses = append(responses, resp)
+		default:
+			return responses
+		}
+	}
+}
+
+// coalesce 将同一个namespace的多次变更合并成一次变更，仍然超出缓冲区大小时丢弃最早的事件
+func (s *Subscription) coalesce(responses []*ApolloResponse) []*ApolloResponse {
+	var (
+		merged []*ApolloResponse
+		index  = map[string]int{}
+	)
+	for _, resp := range responses {
+		i, found := index[resp.Namespace]
+		if !found {
+			index[resp.Namespace] = len(merged)
+			merged = append(merged, resp)
+			continue
+		}
+
+		older := merged[i]
+		merged[i] = &ApolloResponse{
+			Namespace: resp.Namespace,
+			OldValue:  older.OldValue,
+			NewValue:  resp.NewValue,
+			Changes:   older.OldValue.DifferentWith(resp.NewValue, s.agollo.opts.Comparer),
+		}
+	}
+
+	if size := cap(s.ch); len(merged) > size {
+		merged = merged[len(merged)-size:]
+	}
+	atomic.AddUint64(&s.dropped, uint64(len(responses)-len(merged)))
+	return merged
+}
+
+// Subscribe 订阅namespace的配置变更，namespace为空时订阅所有namespace，
+// 与WatchNamespace不同，每次调用都会返回一个独立的订阅
+func (a *agollo) Subscribe(namespace string, opts ...SubscriptionOption) *Subscription {
+	s := &Subscription{
+		agollo: a,
+		opts: SubscriptionOptions{
+			BufferSize:     defaultSubscriptionBufferSize,
+			OverflowPolicy: OverflowBlock,
+		},
+		done: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	if s.opts.BufferSize < 0 || (s.opts.BufferSize == 0 && s.opts.OverflowPolicy != OverflowBlock) {
+		// 丢弃或者合并事件至少需要能缓冲一个事件
+		s.opts.BufferSize = 1
+	}
+	s.ch = make(chan *ApolloResponse, s.opts.BufferSize)
+
+	if namespace != "" {
+		s.namespace = a.opts.fixWatchNamespace(namespace)
+
+		// 非预加载以外的namespace,初始化基础meta信息,否则没有longpoll
+		ctx, cancel := a.withStopContext(context.Background())
+		defer cancel()
+		if err := a.initNamespace(ctx, namespace); err != nil {
+			a.log("Namespace", namespace, "Action", "Subscribe", "Error", err)
+		}
+	}
+
+	a.stopLock.Lock()
+	defer a.stopLock.Unlock()
+	if a.closed {
+		// Shutdown之后返回已关闭的订阅
+		s.close()
+		return s
+	}
+
+	a.subscriptionLock.Lock()
+	defer a.subscriptionLock.Unlock()
+	if a.subscriptions == nil {
+		a.subscriptions = map[string][]*Subscription{}
+	}
+	a.subscriptions[s.namespace] = append(a.subscriptions[s.namespace], s)
+
+	return s
+}
+
+func (a *agollo) removeSubscription(s *Subscription) {
+	a.subscriptionLock.Lock()
+	defer a.subscriptionLock.Unlock()
+
+	subscriptions := a.subscriptions[s.namespace]
+	for i, subscription := range subscriptions {
+		if subscription == s {
+			a.subscriptions[s.namespace] = append(subscriptions[:i:i], subscriptions[i+1:]...)
+			break
+		}
+	}
+	if len(a.subscriptions[s.namespace]) == 0 {
+		delete(a.subscriptions, s.namespace)
+	}
+}
+
+func (a *agollo) sendSubscriptions(resp *ApolloResponse) {
+	a.subscriptionLock.RLock()
+	var subscriptions []*Subscription
+	subscriptions = append(subscriptions, a.subscriptions[""]...)
+	subscriptions = append(subscriptions, a.subscriptions[a.opts.fixWatchNamespace(resp.Namespace)]...)
+	a.subscriptionLock.RUnlock()
+
+	for _, s := range subscriptions {
+		s.send(resp, a.stopCh)
+	}
+}
+
+// closeSubscriptions Shutdown时关闭所有订阅
+func (a *agollo) closeSubscriptions() {
+	a.subscriptionLock.Lock()
+	subscriptions := a.subscriptions
+	a.subscriptions = nil
+	a.subscriptionLock.Unlock()
+
+	for _, ss := range subscriptions {
+		for _, s := range ss {
+			s.close()
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package agollo
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionOverflowPolicy(t *testing.T) {
+	a := &agollo{stopCh: make(chan struct{})}
+	newResponse := func(old, new string) *ApolloResponse {
+		oldValue, newValue := Configurations{"foo": old}, Configurations{"foo": new}
+		return &ApolloResponse{
+			Namespace: "application",
+			OldValue:  oldValue,
+			NewValue:  newValue,
+			Changes:   oldValue.Different(newValue),
+		}
+	}
+	newSubscription := func(opts ...SubscriptionOption) *Subscription {
+		a.subscriptions = nil
+		return a.Subscribe("", opts...)
+	}
+
+	// 丢弃最早的事件
+	s := newSubscription(SubscriptionBufferSize(2), SubscriptionOverflowPolicy(OverflowDropOldest))
+	s.send(newResponse("1", "2"), a.stopCh)
+	s.send(newResponse("2", "3"), a.stopCh)
+	s.send(newResponse("3", "4"), a.stopCh)
+	assert.Equal(t, uint64(1), s.Dropped())
+	assert.Equal(t, "3", (<-s.C()).NewValue["foo"])
+	assert.Equal(t, "4", (<-s.C()).NewValue["foo"])
+
+	// 合并成一个事件
+	s = newSubscription(SubscriptionBufferSize(2), SubscriptionOverflowPolicy(OverflowCoalesce))
+	s.send(newResponse("1", "2"), a.stopCh)
+	s.send(newResponse("2", "3"), a.stopCh)
+	s.send(newResponse("3", "4"), a.stopCh)
+	assert.Equal(t, uint64(2), s.Dropped())
+	assert.Equal(t, newResponse("1", "4"), <-s.C())
+	assert.Len(t, s.C(), 0)
+
+	// 阻塞直到订阅者消费或者关闭订阅
+	s = newSubscription(SubscriptionBufferSize(1))
+	s.send(newResponse("1", "2"), a.stopCh)
+	sent := make(chan struct{})
+	go func() {
+		s.send(newResponse("2", "3"), a.stopCh)
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("send should be blocked")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.Equal(t, "2", (<-s.C()).NewValue["foo"])
+	<-sent
+	assert.Equal(t, "3", (<-s.C()).NewValue["foo"])
+	assert.Equal(t, uint64(0), s.Dropped())
+
+	s.Close()
+	_, ok := <-s.C()
+	assert.False(t, ok)
+}
+
+func TestAgolloSubscribe(t *testing.T) {
+	backupfile, err := ioutil.TempFile("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(backupfile.Name())
+
+	var (
+		lock       sync.Mutex
+		releaseKey = 1
+	)
+	client := &mockApolloClient{
+		notifications: func(configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, []Notification{{NamespaceName: "application", NotificationID: releaseKey}}, nil
+		},
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, &Config{
+				NamespaceName:  "application",
+				Configurations: Configurations{"foo": strconv.Itoa(releaseKey)},
+				ReleaseKey:     strconv.Itoa(releaseKey),
+			}, nil
+		},
+	}
+
+	a, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		PreloadNamespaces("application"),
+		BackupFile(backupfile.Name()),
+	)
+	assert.Nil(t, err)
+
+	// 同一个namespace的多个订阅者都能收到事件
+	s1 := a.Subscribe("application")
+	s2 := a.Subscribe("application.properties")
+
+	lock.Lock()
+	releaseKey++
+	lock.Unlock()
+	a.(*agollo).longPoll(context.Background())
+
+	for _, s := range []*Subscription{s1, s2} {
+		resp := receiveResponse(t, s.C())
+		assert.Equal(t, Changes{{Type: ChangeTypeUpdate, Key: "foo", Value: "2", OldValue: "1", NewValue: "2"}}, resp.Changes)
+	}
+
+	assert.Nil(t, a.Shutdown(context.Background()))
+	_, ok := <-s1.C()
+	assert.False(t, ok)
+	_, ok = <-a.Subscribe("application").C()
+	assert.False(t, ok)
+}
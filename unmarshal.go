@@ -0,0 +1,364 @@
+package agollo
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	unmarshalTagName       = "apollo"
+	unmarshalDefaultOption = "default="
+)
+
+var (
+	ErrUnmarshalTarget = errors.New("unmarshal target must be a non-nil pointer to struct")
+
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Unmarshal 将配置解码到v指向的结构体中，通过tag `apollo:"key,default=value"`指定key及默认值
+//
+// 1. 未设置tag时使用字段名作为key，找不到时会忽略大小写再匹配一次，tag为"-"时跳过该字段
+// 2. 嵌套的结构体以"."拼接key，例如: Timeout字段tag为timeout，所在结构体tag为db，则对应的key为db.timeout
+// 3. 匿名嵌入的结构体不增加前缀
+// 4. slice支持逗号分隔的字符串，例如: hosts=a,b,c
+// 5. map[string]T会收集以"key."为前缀的所有配置项，例如: labels.a=1 labels.b=2
+// 6. 实现了encoding.TextUnmarshaler的类型（例如time.Time）按字符串解码
+// 7. 配置中不存在且没有默认值的字段保持原值
+func (c Configurations) Unmarshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrUnmarshalTarget
+	}
+
+	return c.decodeStruct("", rv.Elem())
+}
+
+type unmarshalTag struct {
+	name       string
+	defaultVal string
+	hasDefault bool
+}
+
+func parseUnmarshalTag(f reflect.StructField) unmarshalTag {
+	var tag unmarshalTag
+	parts := strings.Split(f.Tag.Get(unmarshalTagName), ",")
+	tag.name = strings.TrimSpace(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if strings.HasPrefix(parts[i], unmarshalDefaultOption) {
+			// 默认值中可能包含逗号，例如: default=a,b,c
+			tag.defaultVal = strings.TrimPrefix(strings.Join(parts[i:], ","), unmarshalDefaultOption)
+			tag.hasDefault = true
+			break
+		}
+	}
+	return tag
+}
+
+func (c Configurations) decodeStruct(prefix string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := parseUnmarshalTag(f)
+		if tag.name == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+		if f.Anonymous && tag.name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !reflect.PtrTo(ft).Implements(textUnmarshalerType) {
+				if fv.Kind() == reflect.Ptr {
+					if !fv.CanSet() {
+						continue
+					}
+					if fv.IsNil() {
+						fv.Set(reflect.New(ft))
+					}
+					fv = fv.Elem()
+				}
+				if err := c.decodeStruct(prefix, fv); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !fv.CanSet() {
+			continue
+		}
+
+		name := tag.name
+		if name == "" {
+			name = f.Name
+		}
+		key := prefix + name
+
+		val, found := c.lookup(key)
+		if !found && tag.hasDefault {
+			val, found = tag.defaultVal, true
+		}
+
+		if err := c.decodeValue(key, val, found, fv); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lookup 优先精确匹配key，找不到时忽略大小写匹配
+func (c Configurations) lookup(key string) (interface{}, bool) {
+	if val, found := c[key]; found {
+		return val, true
+	}
+
+	for k, val := range c {
+		if strings.EqualFold(k, key) {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+// hasPrefix 是否存在以prefix开头的配置项，用于判断嵌套结构体的指针是否需要初始化
+func (c Configurations) hasPrefix(prefix string) bool {
+	for k := range c {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Configurations) decodeValue(key string, val interface{}, found bool, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		if !found && !c.hasPrefix(key+".") {
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return c.decodeValue(key, val, found, v.Elem())
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		if !found {
+			return nil
+		}
+		s, err := ToStringE(val)
+		if err != nil {
+			return newUnmarshalError(key, v.Type(), err)
+		}
+		err = v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+		if err != nil {
+			return newUnmarshalError(key, v.Type(), err)
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if m, ok := val.(map[string]interface{}); ok {
+			return Configurations(m).decodeStruct("", v)
+		}
+		return c.decodeStruct(key+".", v)
+	case reflect.Map:
+		return c.decodeMap(key, val, found, v)
+	case reflect.Slice:
+		if !found {
+			return nil
+		}
+		return c.decodeSlice(key, val, v)
+	}
+
+	if !found {
+		return nil
+	}
+
+	if err := setScalar(val, v); err != nil {
+		return newUnmarshalError(key, v.Type(), err)
+	}
+	return nil
+}
+
+func (c Configurations) decodeMap(key string, val interface{}, found bool, v reflect.Value) error {
+	t := v.Type()
+	if t.Key().Kind() != reflect.String {
+		return newUnmarshalError(key, t, errors.New("map key must be string"))
+	}
+
+	entries := Configurations{}
+	if m, ok := val.(map[string]interface{}); found && ok {
+		for k, ev := range m {
+			entries[k] = ev
+		}
+	}
+
+	prefix := key + "."
+	for k, ev := range c {
+		if strings.HasPrefix(k, prefix) {
+			entries[strings.TrimPrefix(k, prefix)] = ev
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+
+	elemType := t.Elem()
+	structElem := elemType.Kind() == reflect.Struct ||
+		(elemType.Kind() == reflect.Ptr && elemType.Elem().Kind() == reflect.Struct)
+	if structElem && !reflect.PtrTo(elemType).Implements(textUnmarshalerType) {
+		// 元素为结构体时，以第一段作为map的key，例如: servers.a.host => servers["a"].Host
+		names := map[string]bool{}
+		for k := range entries {
+			names[strings.SplitN(k, ".", 2)[0]] = true
+		}
+		for name := range names {
+			ev := reflect.New(elemType).Elem()
+			val, found := entries[name]
+			if err := c.decodeValue(prefix+name, val, found, ev); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(name).Convert(t.Key()), ev)
+		}
+		return nil
+	}
+
+	for k, val := range entries {
+		ev := reflect.New(elemType).Elem()
+		if err := c.decodeValue(prefix+k, val, true, ev); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), ev)
+	}
+	return nil
+}
+
+func (c Configurations) decodeSlice(key string, val interface{}, v reflect.Value) error {
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		s, err := ToStringE(val)
+		if err != nil {
+			return newUnmarshalError(key, v.Type(), err)
+		}
+		v.SetBytes([]byte(s))
+		return nil
+	}
+
+	var items []interface{}
+	switch vv := val.(type) {
+	case []interface{}:
+		items = vv
+	default:
+		ss, err := ToStringSliceE(vv)
+		if err != nil {
+			return newUnmarshalError(key, v.Type(), err)
+		}
+		for _, s := range ss {
+			items = append(items, s)
+		}
+	}
+
+	slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := c.decodeValue(fmt.Sprintf("%s[%d]", key, i), item, true, slice.Index(i)); err != nil {
+			return err
+		}
+	}
+	v.Set(slice)
+	return nil
+}
+
+func setScalar(val interface{}, v reflect.Value) error {
+	if v.Type() == durationType {
+		d, err := ToDurationE(val)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s, err := ToStringE(val)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := ToBoolE(val)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := ToInt64E(val)
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(i) {
+			return fmt.Errorf("value %d overflows %s", i, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := ToInt64E(val)
+		if err != nil {
+			return err
+		}
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return fmt.Errorf("value %d overflows %s", i, v.Type())
+		}
+		v.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		f, err := ToFloat64E(val)
+		if err != nil {
+			return err
+		}
+		if v.OverflowFloat(f) {
+			return fmt.Errorf("value %v overflows %s", f, v.Type())
+		}
+		v.SetFloat(f)
+	case reflect.Interface:
+		if val == nil {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		rv := reflect.ValueOf(val)
+		if !rv.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("%T is not assignable to %s", val, v.Type())
+		}
+		v.Set(rv)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// UnmarshalError 描述解码某个配置项时发生的错误
+type UnmarshalError struct {
+	Key  string
+	Type reflect.Type
+	Err  error
+}
+
+func newUnmarshalError(key string, t reflect.Type, err error) error {
+	return &UnmarshalError{Key: key, Type: t, Err: err}
+}
+
+func (e *UnmarshalError) Error() string {
+	return fmt.Sprintf("unmarshal key %s into %s: %v", e.Key, e.Type, e.Err)
+}
@@ -0,0 +1,85 @@
+package agollo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testDBConfig struct {
+	Host    string        `apollo:"host,default=127.0.0.1"`
+	Port    int           `apollo:"port"`
+	Timeout time.Duration `apollo:"timeout,default=3s"`
+}
+
+type testBaseConfig struct {
+	Name string `apollo:"name"`
+}
+
+type testAppConfig struct {
+	testBaseConfig
+	Debug     bool              `apollo:"debug"`
+	Ratio     float64           `apollo:"ratio"`
+	Hosts     []string          `apollo:"hosts,default=a,b"`
+	Ports     []int             `apollo:"ports"`
+	Labels    map[string]string `apollo:"labels"`
+	DB        testDBConfig      `apollo:"db"`
+	Cache     *testDBConfig     `apollo:"cache"`
+	Missing   *testDBConfig     `apollo:"missing"`
+	StartedAt time.Time         `apollo:"started_at"`
+	Version   string
+	Ignored   string `apollo:"-"`
+}
+
+func TestConfigurationsUnmarshal(t *testing.T) {
+	conf := Configurations{
+		"name":       "foo",
+		"debug":      "true",
+		"ratio":      "0.5",
+		"ports":      "80, 443",
+		"labels.a":   "1",
+		"labels.b":   "2",
+		"db.host":    "db.local",
+		"db.port":    "3306",
+		"cache.port": 6379,
+		"started_at": "2020-01-02T03:04:05Z",
+		"version":    "v1",
+		"Ignored":    "ignored",
+	}
+
+	var actual testAppConfig
+	err := conf.Unmarshal(&actual)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "foo", actual.Name)
+	assert.Equal(t, true, actual.Debug)
+	assert.Equal(t, 0.5, actual.Ratio)
+	assert.Equal(t, []string{"a", "b"}, actual.Hosts)
+	assert.Equal(t, []int{80, 443}, actual.Ports)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, actual.Labels)
+	assert.Equal(t, testDBConfig{Host: "db.local", Port: 3306, Timeout: 3 * time.Second}, actual.DB)
+	assert.Equal(t, &testDBConfig{Host: "127.0.0.1", Port: 6379, Timeout: 3 * time.Second}, actual.Cache)
+	assert.Nil(t, actual.Missing)
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), actual.StartedAt)
+	assert.Equal(t, "v1", actual.Version)
+	assert.Empty(t, actual.Ignored)
+}
+
+func TestConfigurationsUnmarshalError(t *testing.T) {
+	var actual testAppConfig
+	err := Configurations{"db.port": "foo"}.Unmarshal(&actual)
+	assert.Error(t, err)
+	if e, ok := err.(*UnmarshalError); assert.True(t, ok) {
+		assert.Equal(t, "db.port", e.Key)
+	}
+
+	err = Configurations{}.Unmarshal(actual)
+	assert.Equal(t, ErrUnmarshalTarget, err)
+
+	var small struct {
+		V int8 `apollo:"v"`
+	}
+	err = Configurations{"v": "1000"}.Unmarshal(&small)
+	assert.Error(t, err)
+}
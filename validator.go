@@ -0,0 +1,55 @@
+package agollo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Validator 在新的release生效前校验配置，返回错误时保留旧的配置，
+// oldValue为当前生效的配置，newValue为解析后的新配置
+type Validator func(oldValue, newValue Configurations) error
+
+// ValidationError 新的release未通过Validator校验
+type ValidationError struct {
+	Namespace  string
+	ReleaseKey string
+	Err        error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("agollo: namespace %s release %s rejected by validator: %v",
+		e.Namespace, e.ReleaseKey, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func isValidationError(err error) bool {
+	var verr *ValidationError
+	return errors.As(err, &verr)
+}
+
+// getValidators 获取namespace的Validator，WithValidator传入的namespace可以带或不带properties后缀
+func (o Options) getValidators(namespace string) []Validator {
+	var validators []Validator
+	for ns, vs := range o.Validators {
+		if o.remoteNamespace(ns) == namespace {
+			validators = append(validators, vs...)
+		}
+	}
+	return validators
+}
+
+func (a *agollo) validate(namespace, releaseKey string, oldValue, newValue Configurations) error {
+	for _, validator := range a.opts.getValidators(namespace) {
+		if err := validator(oldValue, newValue); err != nil {
+			return &ValidationError{
+				Namespace:  namespace,
+				ReleaseKey: releaseKey,
+				Err:        err,
+			}
+		}
+	}
+	return nil
+}
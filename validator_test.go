@@ -0,0 +1,91 @@
+package agollo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgolloValidator(t *testing.T) {
+	var (
+		lock       sync.Mutex
+		releaseKey = 1
+		port       = "8080"
+	)
+	publish := func(p string) {
+		lock.Lock()
+		defer lock.Unlock()
+		releaseKey++
+		port = p
+	}
+
+	client := &mockApolloClient{
+		notifications: func(configServerURL, appID, clusterName string, notifications []Notification) (int, []Notification, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, []Notification{{NamespaceName: "application", NotificationID: releaseKey}}, nil
+		},
+		getConfigsFromNonCache: func(configServerURL, appID, cluster, namespace string, opts ...NotificationsOption) (int, *Config, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return 200, &Config{
+				NamespaceName:  "application",
+				Configurations: Configurations{"port": port},
+				ReleaseKey:     strconv.Itoa(releaseKey),
+			}, nil
+		},
+	}
+
+	errInvalidPort := errors.New("invalid port")
+	store := NewMemoryBackupStore()
+	ag, err := New("localhost:8080", "test",
+		WithApolloClient(client),
+		PreloadNamespaces("application"),
+		WithBackupStore(store),
+		WithValidator("application.properties", func(oldValue, newValue Configurations) error {
+			if _, err := ToIntE(newValue["port"]); err != nil {
+				return errInvalidPort
+			}
+			return nil
+		}),
+	)
+	assert.Nil(t, err)
+	a := ag.(*agollo)
+
+	errorsCh := make(chan *LongPollerError, 1)
+	go func() {
+		errorsCh <- <-a.errorsCh
+	}()
+	time.Sleep(10 * time.Millisecond) // 等待开始接收errors
+
+	// 未通过校验时保留旧的配置，不备份
+	publish("foo")
+	a.longPoll(context.Background())
+	lerr := <-errorsCh
+	var verr *ValidationError
+	assert.True(t, errors.As(lerr.Err, &verr))
+	assert.Equal(t, "application", verr.Namespace)
+	assert.Equal(t, "2", verr.ReleaseKey)
+	assert.True(t, errors.Is(lerr.Err, errInvalidPort))
+
+	assert.Equal(t, "8080", a.Get("port"))
+	backup, err := store.Load("application")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", backup.ReleaseKey)
+	assert.Equal(t, verr, a.Status()["application"].LastError)
+
+	// 更新NotificationID，避免重复获取被拒绝的release
+	notificationID, _ := a.notificationMap.Load("application")
+	assert.Equal(t, 2, notificationID)
+
+	// 通过校验后正常生效
+	publish("9090")
+	a.longPoll(context.Background())
+	assert.Equal(t, "9090", a.Get("port"))
+	assert.Nil(t, a.Status()["application"].LastError)
+}